@@ -0,0 +1,336 @@
+// Package analytics aggregates a user's Uber trip history: how many trips they took, how
+// far they went, how long they waited for their rides and how long the rides took, in
+// total and broken down by product, month, weekday, hour of the day and start city.
+//
+// The history endpoint doesn't return what trips cost. Spend is aggregated, per
+// currency, if the fares of the trips are supplied with `WithFares`.
+//
+//	activity, err := client.GetUserActivityVersion(ctx, uber.HistoryV12, 0, uber.MaxHistoryLimit)
+//	summary := analytics.Summarize(activity.History, time.Local)
+//	summary.WriteText(os.Stdout)
+package analytics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	uber "github.com/r-medina/go-uber"
+)
+
+// Option configures `Summarize`.
+type Option func(*config)
+
+type config struct {
+	fare func(*uber.Trip) (float64, string)
+}
+
+// WithFares makes `Summarize` aggregate what the trips cost, as told by `fare`: the
+// amount of a trip and its currency, eg: 12.5, "USD". Trips whose currency is "" are
+// taken to have no known fare.
+func WithFares(fare func(trip *uber.Trip) (amount float64, currency string)) Option {
+	return func(c *config) {
+		c.fare = fare
+	}
+}
+
+// Summary is the aggregation of a list of trips. It can be written as text tables with
+// `WriteText`, or marshalled to JSON.
+type Summary struct {
+	// All the trips
+	Total Stats `json:"total"`
+
+	// By `Trip.ProductID`, the most taken product first
+	ByProduct []Group `json:"by_product"`
+
+	// By the month the trips were requested in, eg: "2015-04", in order
+	ByMonth []Group `json:"by_month"`
+
+	// By the weekday the trips were requested on, eg: "Monday", from Sunday on
+	ByWeekday []Group `json:"by_weekday"`
+
+	// By the hour the trips were requested at, eg: "08", from midnight on
+	ByHour []Group `json:"by_hour"`
+
+	// By `Trip.StartCity`, the most visited city first. Only `uber.HistoryV12` has
+	// cities; the trips of other versions are under "".
+	ByCity []Group `json:"by_city"`
+}
+
+// Group is the `Stats` of the trips that share a `Key`.
+type Group struct {
+	// eg: "2015-04"
+	Key string `json:"key"`
+
+	Stats
+}
+
+// Stats are the metrics of a set of trips.
+type Stats struct {
+	// eg: 12
+	Trips int `json:"trips"`
+
+	// In miles
+	// eg: 24.6
+	Distance float64 `json:"distance"`
+
+	// In miles
+	// eg: 2.05
+	AverageDistance float64 `json:"average_distance"`
+
+	// From the request to the pickup (`StartedAt - RequestedAt`)
+	TimeToPickup Durations `json:"time_to_pickup"`
+
+	// From the pickup to the dropoff (`EndedAt - StartedAt`)
+	RideDuration Durations `json:"ride_duration"`
+
+	// What the trips cost, one per currency in order, if `WithFares` was given
+	Spend []Spend `json:"spend,omitempty"`
+}
+
+// Spend aggregates the fares of a set of trips in a currency.
+type Spend struct {
+	// eg: "USD"
+	Currency string `json:"currency"`
+
+	// Number of trips with a fare in `Currency`
+	Trips int `json:"trips"`
+
+	// eg: 38.2
+	Total float64 `json:"total"`
+
+	// eg: 12.73
+	Average float64 `json:"average"`
+}
+
+// Durations aggregates a duration over a set of trips. Trips missing either time are
+// left out, so `Count` may be less than the number of trips.
+type Durations struct {
+	// Number of trips the duration is known for
+	Count int
+
+	Total   time.Duration
+	Average time.Duration
+	Min     time.Duration
+	Max     time.Duration
+}
+
+// MarshalJSON implements `json.Marshaler`. The durations are in seconds.
+func (d Durations) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Count   int     `json:"count"`
+		Total   float64 `json:"total"`
+		Average float64 `json:"average"`
+		Min     float64 `json:"min"`
+		Max     float64 `json:"max"`
+	}{d.Count, d.Total.Seconds(), d.Average.Seconds(), d.Min.Seconds(), d.Max.Seconds()})
+}
+
+func (d *Durations) add(start, end uber.Timestamp) {
+	if start.IsZero() || end.IsZero() || end.Before(start.Time) {
+		return
+	}
+
+	dur := end.Sub(start.Time)
+	if d.Count == 0 || dur < d.Min {
+		d.Min = dur
+	}
+	if dur > d.Max {
+		d.Max = dur
+	}
+	d.Count++
+	d.Total += dur
+	d.Average = d.Total / time.Duration(d.Count)
+}
+
+func (s *Stats) add(trip *uber.Trip, amount float64, currency string) {
+	s.Trips++
+	s.Distance += trip.Distance
+	s.AverageDistance = s.Distance / float64(s.Trips)
+	s.TimeToPickup.add(trip.RequestedAt, trip.StartedAt)
+	s.RideDuration.add(trip.StartedAt, trip.EndedAt)
+
+	if currency == "" {
+		return
+	}
+	i := sort.Search(len(s.Spend), func(i int) bool { return s.Spend[i].Currency >= currency })
+	if i == len(s.Spend) || s.Spend[i].Currency != currency {
+		s.Spend = append(s.Spend, Spend{})
+		copy(s.Spend[i+1:], s.Spend[i:])
+		s.Spend[i] = Spend{Currency: currency}
+	}
+	spend := &s.Spend[i]
+	spend.Trips++
+	spend.Total += amount
+	spend.Average = spend.Total / float64(spend.Trips)
+}
+
+// Summarize aggregates `trips`. The months, weekdays and hours are those of the time
+// each trip was requested in `loc`, or in UTC if `loc` is nil.
+func Summarize(trips []*uber.Trip, loc *time.Location, opts ...Option) *Summary {
+	if loc == nil {
+		loc = time.UTC
+	}
+	c := new(config)
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	byProduct := make(map[string]*Stats)
+	byMonth := make(map[string]*Stats)
+	byWeekday := make(map[string]*Stats)
+	byHour := make(map[string]*Stats)
+	byCity := make(map[string]*Stats)
+
+	s := new(Summary)
+	for _, trip := range trips {
+		var amount float64
+		var currency string
+		if c.fare != nil {
+			amount, currency = c.fare(trip)
+		}
+
+		s.Total.add(trip, amount, currency)
+		statsFor(byProduct, trip.ProductID).add(trip, amount, currency)
+		statsFor(byCity, city(trip)).add(trip, amount, currency)
+
+		t := trip.RequestedAt.Time
+		if t.IsZero() {
+			t = trip.StartedAt.Time
+		}
+		if t.IsZero() {
+			continue
+		}
+
+		t = t.In(loc)
+		statsFor(byMonth, t.Format("2006-01")).add(trip, amount, currency)
+		statsFor(byWeekday, t.Weekday().String()).add(trip, amount, currency)
+		statsFor(byHour, t.Format("15")).add(trip, amount, currency)
+	}
+
+	s.ByProduct = byTrips(byProduct)
+	s.ByMonth = byKey(byMonth)
+	s.ByHour = byKey(byHour)
+	s.ByCity = byTrips(byCity)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if stats, ok := byWeekday[day.String()]; ok {
+			s.ByWeekday = append(s.ByWeekday, Group{Key: day.String(), Stats: *stats})
+		}
+	}
+
+	return s
+}
+
+func city(trip *uber.Trip) string {
+	if trip.StartCity == nil {
+		return ""
+	}
+
+	return trip.StartCity.DisplayName
+}
+
+func statsFor(groups map[string]*Stats, key string) *Stats {
+	stats, ok := groups[key]
+	if !ok {
+		stats = new(Stats)
+		groups[key] = stats
+	}
+
+	return stats
+}
+
+func toGroups(m map[string]*Stats) []Group {
+	groups := make([]Group, 0, len(m))
+	for key, stats := range m {
+		groups = append(groups, Group{Key: key, Stats: *stats})
+	}
+
+	return groups
+}
+
+// byKey returns the groups in the order of their keys.
+func byKey(m map[string]*Stats) []Group {
+	groups := toGroups(m)
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Key < groups[j].Key })
+
+	return groups
+}
+
+// byTrips returns the groups with the most trips first.
+func byTrips(m map[string]*Stats) []Group {
+	groups := toGroups(m)
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Trips != groups[j].Trips {
+			return groups[i].Trips > groups[j].Trips
+		}
+		return groups[i].Key < groups[j].Key
+	})
+
+	return groups
+}
+
+// WriteText writes the summary to `w` as text tables, one per breakdown. There is a
+// spend column if any trip had a fare.
+func (s *Summary) WriteText(w io.Writer) error {
+	spend := len(s.Total.Spend) > 0
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	sections := []struct {
+		title  string
+		groups []Group
+	}{
+		{"total", []Group{{Key: "all", Stats: s.Total}}},
+		{"product", s.ByProduct},
+		{"month", s.ByMonth},
+		{"weekday", s.ByWeekday},
+		{"hour", s.ByHour},
+		{"city", s.ByCity},
+	}
+	for i, section := range sections {
+		if i > 0 {
+			fmt.Fprintln(tw, "\t")
+		}
+
+		fmt.Fprintf(tw, "%s\ttrips\tmiles\tavg miles\tavg pickup\tavg ride\t", section.title)
+		if spend {
+			fmt.Fprint(tw, "spend\t")
+		}
+		fmt.Fprintln(tw)
+
+		for _, g := range section.groups {
+			key := g.Key
+			if key == "" {
+				key = "-"
+			}
+			fmt.Fprintf(
+				tw, "%s\t%d\t%.1f\t%.1f\t%s\t%s\t", key, g.Trips, g.Distance,
+				g.AverageDistance, g.TimeToPickup.Average, g.RideDuration.Average,
+			)
+			if spend {
+				fmt.Fprintf(tw, "%s\t", formatSpend(g.Spend))
+			}
+			fmt.Fprintln(tw)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// formatSpend returns the totals of `spend`, eg: "38.20 USD, 12.00 EUR", or "-".
+func formatSpend(spend []Spend) string {
+	if len(spend) == 0 {
+		return "-"
+	}
+
+	totals := make([]string, len(spend))
+	for i, sp := range spend {
+		totals[i] = fmt.Sprintf("%.2f %s", sp.Total, sp.Currency)
+	}
+
+	return strings.Join(totals, ", ")
+}
@@ -0,0 +1,171 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	uber "github.com/r-medina/go-uber"
+)
+
+var sf = &uber.City{DisplayName: "San Francisco"}
+
+func at(sec int64) uber.Timestamp {
+	return uber.Timestamp{Time: time.Unix(sec, 0)}
+}
+
+// 2015-04-12 22:02:45 UTC is a Sunday
+var testTrips = []*uber.Trip{
+	{
+		ProductID: "uberX", Distance: 2, StartCity: sf,
+		RequestedAt: at(1428876165), StartedAt: at(1428876165 + 120),
+		EndedAt: at(1428876165 + 720),
+	},
+	{
+		ProductID: "uberX", Distance: 4, StartCity: sf,
+		RequestedAt: at(1428876165 + 86400), StartedAt: at(1428876165 + 86400 + 360),
+		EndedAt: at(1428876165 + 86400 + 1560),
+	},
+	{
+		// a trip that was never picked up
+		ProductID: "uberBLACK", Distance: 0, RequestedAt: at(1431554565),
+	},
+}
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+
+	s := Summarize(testTrips, nil)
+
+	total := s.Total
+	if total.Trips != 3 || total.Distance != 6 || total.AverageDistance != 2 {
+		t.Fatalf("unexpected total %+v", total)
+	}
+	if total.TimeToPickup.Count != 2 || total.TimeToPickup.Average != 4*time.Minute ||
+		total.TimeToPickup.Min != 2*time.Minute || total.TimeToPickup.Max != 6*time.Minute {
+		t.Fatalf("unexpected time to pickup %+v", total.TimeToPickup)
+	}
+	if total.RideDuration.Total != 30*time.Minute || total.RideDuration.Average != 15*time.Minute {
+		t.Fatalf("unexpected ride duration %+v", total.RideDuration)
+	}
+
+	expected := map[string][]string{
+		"product": {"uberX", "uberBLACK"},
+		"month":   {"2015-04", "2015-05"},
+		"weekday": {"Sunday", "Monday", "Wednesday"},
+		"hour":    {"22"},
+		"city":    {"San Francisco", ""},
+	}
+	actual := map[string][]Group{
+		"product": s.ByProduct,
+		"month":   s.ByMonth,
+		"weekday": s.ByWeekday,
+		"hour":    s.ByHour,
+		"city":    s.ByCity,
+	}
+	for name, keys := range expected {
+		groups := actual[name]
+		if len(groups) != len(keys) {
+			t.Fatalf("expected %d groups by %s, got %+v", len(keys), name, groups)
+		}
+		for i, key := range keys {
+			if groups[i].Key != key {
+				t.Fatalf("expected group %d by %s to be %q, got %q", i, name, key, groups[i].Key)
+			}
+		}
+	}
+
+	if s.ByProduct[0].Trips != 2 || s.ByProduct[0].Distance != 6 {
+		t.Fatalf("unexpected uberX stats %+v", s.ByProduct[0])
+	}
+}
+
+func TestSummarizeLocation(t *testing.T) {
+	t.Parallel()
+
+	// 22:02 UTC is 15:02 in San Francisco, on the same day
+	loc := time.FixedZone("PDT", -7*60*60)
+	s := Summarize(testTrips[:1], loc)
+
+	if s.ByHour[0].Key != "15" || s.ByWeekday[0].Key != "Sunday" {
+		t.Fatalf("expected Sunday at 15, got %s at %s", s.ByWeekday[0].Key, s.ByHour[0].Key)
+	}
+}
+
+func TestSummarizeSpend(t *testing.T) {
+	t.Parallel()
+
+	fares := map[*uber.Trip]struct {
+		amount   float64
+		currency string
+	}{
+		testTrips[0]: {10, "USD"},
+		testTrips[1]: {15, "USD"},
+		testTrips[2]: {20, "EUR"},
+	}
+	s := Summarize(testTrips, nil, WithFares(func(trip *uber.Trip) (float64, string) {
+		return fares[trip].amount, fares[trip].currency
+	}))
+
+	expected := []Spend{
+		{Currency: "EUR", Trips: 1, Total: 20, Average: 20},
+		{Currency: "USD", Trips: 2, Total: 25, Average: 12.5},
+	}
+	if !reflect.DeepEqual(s.Total.Spend, expected) {
+		t.Fatalf("expected spend %+v, got %+v", expected, s.Total.Spend)
+	}
+	if spend := s.ByProduct[0].Spend; len(spend) != 1 || spend[0].Total != 25 {
+		t.Fatalf("unexpected uberX spend %+v", spend)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := s.WriteText(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "20.00 EUR, 25.00 USD") {
+		t.Fatalf("expected the spend in:\n%s", buf)
+	}
+
+	// without fares, there is no spend
+	if s := Summarize(testTrips, nil); s.Total.Spend != nil {
+		t.Fatalf("expected no spend, got %+v", s.Total.Spend)
+	}
+}
+
+func TestSummaryOutput(t *testing.T) {
+	t.Parallel()
+
+	s := Summarize(testTrips, nil)
+
+	buf := new(bytes.Buffer)
+	if err := s.WriteText(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"avg pickup", "uberX", "2015-04", "Monday", "San Francisco"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Fatalf("expected %q in:\n%s", line, buf)
+		}
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(struct {
+		Total struct {
+			Trips        int
+			TimeToPickup struct{ Average float64 } `json:"time_to_pickup"`
+		}
+		ByProduct []struct{ Key string } `json:"by_product"`
+	})
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Total.Trips != 3 || decoded.Total.TimeToPickup.Average != 240 ||
+		decoded.ByProduct[0].Key != "uberX" {
+		t.Fatalf("unexpected JSON %s", data)
+	}
+}
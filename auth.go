@@ -1,12 +1,22 @@
 package uber
 
 import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/skratchdot/open-golang/open"
 )
@@ -24,6 +34,10 @@ type access struct {
 	// 30 days from request
 	ExpiresIn int `json:"expires_in"`
 
+	// Not part of the api's response. Set by the client when it receives the token so
+	// that it can tell when `ExpiresIn` runs out.
+	IssuedAt time.Time `json:"issued_at,omitempty"`
+
 	// When the user's access_token has expired, you may obtain a fresh access_token
 	// by exchanging the refresh_token that is associated with the access_token
 	RefreshToken string `json:"refresh_token"`
@@ -38,9 +52,34 @@ type access struct {
 	//
 	// `history` - Pull trip data including the locations, times, and product type of
 	// a user's historical pickups and drop-offs.
+	//
+	// The granted scopes are separated by spaces (see `Client.GrantedScopes`).
 	Scope string `json:"scope"`
 }
 
+// scopes returns the scopes that were granted with this token.
+func (a *access) scopes() []Scope {
+	var scopes []Scope
+	separator := func(r rune) bool { return r == ' ' || r == ',' }
+	for _, scope := range strings.FieldsFunc(a.Scope, separator) {
+		scopes = append(scopes, Scope(scope))
+	}
+
+	return scopes
+}
+
+// expiresWithin reports whether the access token expires within `d`. Tokens whose
+// lifetime is unknown are never considered expired; the api will answer with a 401
+// and the client refreshes then.
+func (a *access) expiresWithin(d time.Duration) bool {
+	if a.IssuedAt.IsZero() || a.ExpiresIn <= 0 {
+		return false
+	}
+
+	expiry := a.IssuedAt.Add(time.Duration(a.ExpiresIn) * time.Second)
+	return time.Now().Add(d).After(expiry)
+}
+
 // auth is the data structure needed to complete OAuth flow.
 type auth struct {
 	clientID string `query:"client_id,required"`
@@ -55,91 +94,583 @@ type auth struct {
 // strictly programatically because of the multi-step OAuth process. This method
 // returns the URL that the user needs to go to in order for Uber to authorize your
 // app and give you a authorization code.
+//
+// Every call generates a new random `state` parameter. Uber sends it back along with
+// the code, and it must be checked (see `Client.Exchange` and `Client.CheckState`)
+// before the code is used, which protects against CSRF. It is only valid for
+// `StateTTL`.
+//
+// Every call also generates a PKCE (RFC 7636) code verifier, whose S256 challenge is
+// part of the URL, and which is sent when exchanging the code. This means that public
+// clients, such as desktop apps, can leave `clientSecret` empty rather than ship it.
+//
+// See `OAuthScopes` to pass the scopes as `Scope`s.
 func (c *Client) OAuth(
 	clientID, clientSecret, redirect string, scope ...string,
 ) (string, error) {
-	c.auth = new(auth)
-	c.clientID = clientID
-	c.clientSecret = clientSecret
-	c.redirectURI = redirect
+	return c.OAuthScopes(clientID, clientSecret, redirect, toScopes(scope)...)
+}
 
-	return c.generateRequestURL(AuthHost, AccessCodeEndpoint, authReq{
-		auth:         *c.auth,
-		responseType: "code",
-		scope:        strings.Join(scope, " "), // profile,history
-		state:        State,
+// OAuthScopes is like `OAuth` but takes the scopes as `Scope`s, eg: `ScopeProfile`.
+//
+// Any scope is passed on to Uber, including ones this package has no constant for, eg:
+// `Scope("new_scope")`. Only an empty scope, or one with spaces (the separator of scopes
+// in the URL), is an error.
+func (c *Client) OAuthScopes(
+	clientID, clientSecret, redirect string, scope ...Scope,
+) (string, error) {
+	scopes := make([]string, len(scope))
+	for i, s := range scope {
+		if s == "" || strings.ContainsAny(string(s), " \t\r\n") {
+			return "", fmt.Errorf("uber: invalid scope %q", s)
+		}
+		scopes[i] = string(s)
+	}
+
+	c.SetCredentials(clientID, clientSecret, redirect)
+
+	flow, err := c.newFlow()
+	if err != nil {
+		return "", err
+	}
+
+	return c.generateRequestURL(c.authHost, AccessCodeEndpoint, authReq{
+		auth:                *c.auth,
+		responseType:        "code",
+		scope:               strings.Join(scopes, " "), // profile history
+		state:               flow.state,
+		codeChallenge:       pkceChallenge(flow.verifier),
+		codeChallengeMethod: "S256",
 	})
 }
 
+// Exchange checks that `state` is that of an authorization attempt started with
+// `OAuth` and, if so, exchanges the authorization `code` for an access token as
+// `SetAccessToken` does. These are the two query parameters Uber adds to your redirect
+// URL.
+func (c *Client) Exchange(ctx context.Context, state, code string) error {
+	f, err := c.takeFlow(state)
+	if err != nil {
+		return err
+	}
+	if code == "" {
+		return errors.New("uber: no authorization code")
+	}
+
+	return c.exchangeCode(ctx, code, f)
+}
+
+// CheckState checks that `state` is that of an authorization attempt started with
+// `OAuth` less than `StateTTL` ago. A state can only be checked once, so replayed
+// callbacks are rejected. The error, if any, is a `*StateError`.
+//
+// The attempt that was checked becomes the latest one, so that the `SetAccessToken`
+// that usually follows sends its code verifier.
+func (c *Client) CheckState(state string) error {
+	f, err := c.takeFlow(state)
+	if err != nil {
+		return err
+	}
+
+	c.flowsMu.Lock()
+	c.latestFlow = f
+	c.flowsMu.Unlock()
+
+	return nil
+}
+
+// StateTTL is how long the user has to complete an authorization attempt.
+const StateTTL = 10 * time.Minute
+
+var (
+	// ErrUnknownState is wrapped by the `StateError` returned when a callback carries
+	// a state that the client never generated or that has already been used.
+	ErrUnknownState = errors.New("unknown state")
+
+	// ErrExpiredState is wrapped by the `StateError` returned when a callback comes
+	// more than `StateTTL` after its authorization attempt began.
+	ErrExpiredState = errors.New("expired state")
+)
+
+// StateError is returned when the state of an authorization callback cannot be
+// verified. This is evidence of tampering, or of a very slow user.
+type StateError struct {
+	// The state that was received
+	State string
+
+	// eg: `ErrUnknownState`, `ErrExpiredState`
+	Err error
+}
+
+// Error implements the `error` interface for `StateError`.
+func (err *StateError) Error() string {
+	return fmt.Sprintf("uber: evidence of tampering--%v %q", err.Err, err.State)
+}
+
+// Unwrap returns the underlying error.
+func (err *StateError) Unwrap() error {
+	return err.Err
+}
+
+// flow is a single attempt at authorizing the client, started by `OAuth`.
+type flow struct {
+	state    string
+	verifier string // PKCE code verifier
+	created  time.Time
+}
+
+// newFlow starts an authorization attempt with a fresh random state and code verifier,
+// and forgets the attempts that have expired.
+func (c *Client) newFlow() (*flow, error) {
+	state, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	// 32 bytes make for 43 characters, the minimum length of a verifier
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	c.flowsMu.Lock()
+	defer c.flowsMu.Unlock()
+
+	if c.flows == nil {
+		c.flows = make(map[string]*flow)
+	}
+	for s, f := range c.flows {
+		if time.Since(f.created) > StateTTL {
+			delete(c.flows, s)
+		}
+	}
+
+	f := &flow{state: state, verifier: verifier, created: time.Now()}
+	c.flows[state] = f
+	c.latestFlow = f
+
+	return f, nil
+}
+
+// takeFlow returns the authorization attempt with the given state and forgets it.
+func (c *Client) takeFlow(state string) (*flow, error) {
+	c.flowsMu.Lock()
+	defer c.flowsMu.Unlock()
+
+	f, ok := c.flows[state]
+	if !ok || state == "" {
+		return nil, &StateError{State: state, Err: ErrUnknownState}
+	}
+	delete(c.flows, state)
+	if c.latestFlow == f {
+		c.latestFlow = nil
+	}
+
+	if time.Since(f.created) > StateTTL {
+		return nil, &StateError{State: state, Err: ErrExpiredState}
+	}
+
+	return f, nil
+}
+
+// pkceChallenge returns the S256 code challenge for a PKCE code verifier.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomString returns `n` bytes from crypto/rand, base64 (URL) encoded.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// GrantedScopes returns the scopes the user granted your app. It is empty until an
+// access token has been set.
+func (c *Client) GrantedScopes() []Scope {
+	return c.currentAccess().scopes()
+}
+
+// HasScope reports whether the user granted your app `scope`.
+func (c *Client) HasScope(scope Scope) bool {
+	for _, s := range c.GrantedScopes() {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requireScope returns an `*ErrMissingScope` naming the first of `scopes` unless the
+// user granted at least one of them. If the granted scopes are unknown, eg: because no
+// token has been set yet, the api is left to decide.
+func (c *Client) requireScope(scopes ...Scope) error {
+	if len(c.GrantedScopes()) == 0 {
+		return nil
+	}
+
+	for _, scope := range scopes {
+		if c.HasScope(scope) {
+			return nil
+		}
+	}
+
+	return &ErrMissingScope{Scope: scopes[0]}
+}
+
+// SetCredentials sets your app's OAuth credentials without starting the authorization
+// flow. `OAuth` does this for you, but a client whose token was restored from a
+// `TokenStore` needs the credentials to refresh it.
+func (c *Client) SetCredentials(clientID, clientSecret, redirect string) {
+	c.auth = &auth{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURI:  redirect,
+	}
+}
+
 // AutOAuth automatically does the authorization flow by opening the user's browser,
 // asking them to authorize, then booting up a server to deal with the user's redirect and
 // authorizing your client.
 func (c *Client) AutOAuth(
 	clientID, clientSecret, redirect string, scope ...string,
 ) error {
-	urlString, err := c.OAuth(clientID, clientSecret, redirect, scope...)
+	return c.AutOAuthContext(
+		context.Background(), clientID, clientSecret, redirect, toScopes(scope),
+	)
+}
+
+// toScopes converts scopes given as strings.
+func toScopes(scope []string) []Scope {
+	scopes := make([]Scope, len(scope))
+	for i, s := range scope {
+		scopes[i] = Scope(s)
+	}
+
+	return scopes
+}
+
+// AutOAuthContext is like `AutOAuth` but takes a context, which bounds how long to wait
+// for the user, and options.
+//
+// The server that receives the redirect uses its own mux and shuts down as soon as it
+// has handled the callback (or the context is done). By default it listens on the host
+// and port of `redirect`; if that is a loopback address without a port, eg:
+// "http://localhost/callback", an ephemeral port is picked. See `WithListener` to
+// supply a listener instead.
+//
+// On machines without a browser, eg: over SSH, use `WithHeadless`.
+func (c *Client) AutOAuthContext(
+	ctx context.Context, clientID, clientSecret, redirect string, scope []Scope,
+	opts ...AuthOption,
+) error {
+	config := &authFlowConfig{browser: SystemBrowser}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.headless {
+		return c.headlessOAuth(ctx, clientID, clientSecret, redirect, scope, config)
+	}
+
+	server, err := newCallbackServer(ctx, redirect, config.listener,
+		func(ctx context.Context, query url.Values) error {
+			// eg: the user denied access
+			if e := query.Get("error"); e != "" {
+				return &authError{Err: e}
+			}
+
+			return c.Exchange(ctx, query.Get("state"), query.Get("code"))
+		},
+	)
 	if err != nil {
-		return nil
+		return err
 	}
 
-	httpDone := make(chan struct{})
-	httpErr := make(chan error)
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		state := r.URL.Query().Get("state")
-		if state != State {
-			httpErr <- fmt.Errorf("uber: evidence of tampering--incorrect state %s", state)
-		}
-		code := r.URL.Query().Get("code")
-		if code == "" {
-			httpErr <- errors.New("uber: an unidentified error occured")
-		}
+	urlString, err := c.OAuthScopes(clientID, clientSecret, server.url, scope...)
+	if err != nil {
+		server.close()
+		return err
+	}
+
+	if err := config.browser.Open(urlString); err != nil {
+		server.close()
+		return err
+	}
+
+	return server.wait(ctx)
+}
 
-		err = c.SetAccessToken(code)
+// headlessOAuth prints the authorization URL and reads back the URL the user was
+// redirected to, or just the code in it.
+func (c *Client) headlessOAuth(
+	ctx context.Context, clientID, clientSecret, redirect string, scope []Scope,
+	config *authFlowConfig,
+) error {
+	urlString, err := c.OAuthScopes(clientID, clientSecret, redirect, scope...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(config.out, "Go to the following URL to authorize this app:\n\n%s\n\n", urlString)
+	fmt.Fprint(config.out, "Then paste the URL you were redirected to (or its code): ")
+
+	line, err := readLine(ctx, config.in)
+	if err != nil {
+		return err
+	}
+
+	state, code, err := parseRedirect(line)
+	if err != nil {
+		return err
+	}
+
+	// a bare code comes without its state; it can only be for the attempt we started
+	if state == "" {
+		u, err := url.Parse(urlString)
 		if err != nil {
-			httpErr <- err
+			return err
 		}
+		state = u.Query().Get("state")
+	}
 
-		fmt.Fprintf(w, `<script type="text/javascript\">close()</script>
-you may close this webpage`)
-		close(httpDone)
-	})
+	return c.Exchange(ctx, state, code)
+}
 
+// readLine reads a line from `in`, or returns the error of `ctx` if it is done first.
+func readLine(ctx context.Context, in io.Reader) (string, error) {
+	// reading can't be interrupted, so it is left behind if the context is done first
+	lines := make(chan string, 1)
+	errs := make(chan error, 1)
 	go func() {
-		httpErr <- http.ListenAndServe(Port, nil)
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			errs <- err
+			return
+		}
+		lines <- line
 	}()
 
-	err = open.Run(urlString)
+	select {
+	case line := <-lines:
+		return line, nil
+	case err := <-errs:
+		return "", err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// parseRedirect returns the state and code in `input`, which is either the URL Uber
+// redirected the user to or just the code.
+func parseRedirect(input string) (state, code string, err error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", "", errors.New("uber: no authorization code")
+	}
+
+	if !strings.ContainsAny(input, "?=&/") {
+		return "", input, nil
+	}
+
+	u, err := url.Parse(input)
 	if err != nil {
-		return err
+		return "", "", err
+	}
+	query := u.Query()
+	if u.RawQuery == "" {
+		// eg: "state=...&code=..." without the rest of the URL
+		if query, err = url.ParseQuery(input); err != nil {
+			return "", "", err
+		}
 	}
 
-	select {
-	case err := <-httpErr:
-		return err
-	case <-httpDone:
-		return nil
+	if e := query.Get("error"); e != "" {
+		return "", "", &authError{Err: e}
+	}
+	if query.Get("code") == "" {
+		return "", "", errors.New("uber: no authorization code")
+	}
+
+	return query.Get("state"), query.Get("code"), nil
+}
+
+// Browser opens URLs for the user during the automatic authorization flow.
+type Browser interface {
+	Open(url string) error
+}
+
+// BrowserFunc adapts a function to the `Browser` interface.
+type BrowserFunc func(url string) error
+
+// Open implements `Browser`.
+func (f BrowserFunc) Open(url string) error {
+	return f(url)
+}
+
+// SystemBrowser opens URLs in the user's default browser. It is the `Browser` used by
+// the automatic authorization flow unless told otherwise.
+var SystemBrowser Browser = BrowserFunc(open.Run)
+
+// AuthOption configures the automatic authorization flow (see `AutOAuthContext`), and
+// the surge confirmation flow (see `ConfirmSurge`).
+type AuthOption func(*authFlowConfig)
+
+// authFlowConfig holds the settings of an automatic authorization flow.
+type authFlowConfig struct {
+	listener net.Listener
+	browser  Browser
+
+	// headless flows don't start a server; the user pastes the redirect URL in
+	headless bool
+	in       io.Reader
+	out      io.Writer
+}
+
+// WithListener makes the automatic authorization flow receive the redirect on `l`. The
+// listener is closed when the flow is over.
+func WithListener(l net.Listener) AuthOption {
+	return func(config *authFlowConfig) {
+		config.listener = l
+	}
+}
+
+// WithBrowser makes the automatic authorization flow open the authorization URL with
+// `b` rather than `SystemBrowser`.
+func WithBrowser(b Browser) AuthOption {
+	return func(config *authFlowConfig) {
+		config.browser = b
+	}
+}
+
+// WithHeadless makes the automatic authorization flow work without a browser or a
+// server, eg: over SSH. The authorization URL is written to `out`, and the user is
+// expected to open it anywhere and type into `in` the URL they are redirected to, or
+// just the code in it. A nil `in` or `out` is `os.Stdin` or `os.Stdout`.
+func WithHeadless(in io.Reader, out io.Writer) AuthOption {
+	if in == nil {
+		in = os.Stdin
+	}
+	if out == nil {
+		out = os.Stdout
+	}
+
+	return func(config *authFlowConfig) {
+		config.headless = true
+		config.in = in
+		config.out = out
 	}
 }
 
 // SetAccessToken completes the third step of the authorization process.
 // Once the user generates an authorization code
 func (c *Client) SetAccessToken(authorizationCode string) error {
+	return c.SetAccessTokenContext(context.Background(), authorizationCode)
+}
+
+// SetAccessTokenContext is like `SetAccessToken` but takes a context.
+//
+// The PKCE code verifier sent along with the code is that of the latest authorization
+// attempt, or of the one last checked with `CheckState`. Use `Client.Exchange` to match
+// the code with its own attempt instead.
+func (c *Client) SetAccessTokenContext(ctx context.Context, authorizationCode string) error {
+	c.flowsMu.Lock()
+	latest := c.latestFlow
+	c.flowsMu.Unlock()
+
+	return c.exchangeCode(ctx, authorizationCode, latest)
+}
+
+// exchangeCode exchanges an authorization code obtained during the authorization
+// attempt `f` for an access token. `f` may be nil if the attempt is unknown.
+func (c *Client) exchangeCode(ctx context.Context, code string, f *flow) error {
+	var verifier string
+	if f != nil {
+		verifier = f.verifier
+	}
+
 	payload, err := c.generateRequestURLHelper(reflect.ValueOf(accReq{
 		auth:         *c.auth,
 		clientSecret: c.clientSecret,
 		grantType:    "authorization_code",
-		code:         authorizationCode,
+		code:         code,
+		codeVerifier: verifier,
+	}))
+	if err != nil {
+		return err
+	}
+
+	return c.requestAccessToken(ctx, payload)
+}
+
+// refreshAccessToken exchanges the client's refresh token for a fresh access token.
+// `stale` is the access token the caller was using: if another goroutine has already
+// replaced it by the time the lock is acquired, there is nothing left to do. This
+// keeps a client shared between many goroutines from refreshing more than once.
+//
+// A refreshed token that the token store fails to save is still used, so the error
+// goes to `onTokenSaveError` rather than failing the caller.
+func (c *Client) refreshAccessToken(ctx context.Context, stale string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	current := c.currentAccess()
+	if current.Token != stale {
+		return nil
+	}
+	if current.RefreshToken == "" {
+		return errors.New("uber: access token expired and there is no refresh token")
+	}
+	if c.auth == nil {
+		return errors.New("uber: cannot refresh the access token without client credentials")
+	}
+
+	payload, err := c.generateRequestURLHelper(reflect.ValueOf(refreshReq{
+		auth:         *c.auth,
+		clientSecret: c.clientSecret,
+		grantType:    "refresh_token",
+		refreshToken: current.RefreshToken,
 	}))
 	if err != nil {
 		return err
 	}
 
-	res, err := c.httpClient.PostForm(
-		fmt.Sprintf("%s/%s", AuthHost, AccessTokenEndpoint), payload,
+	err = c.requestAccessToken(ctx, payload)
+	var saveErr *TokenSaveError
+	if errors.As(err, &saveErr) {
+		if c.onTokenSaveError != nil {
+			c.onTokenSaveError(err)
+		}
+		return nil
+	}
+
+	return err
+}
+
+// requestAccessToken posts `payload` to the `AccessTokenEndpoint` and, on success,
+// stores the resulting tokens on the client. They are stored even if saving them to the
+// token store fails, in which case the error is a `*TokenSaveError`.
+func (c *Client) requestAccessToken(ctx context.Context, payload url.Values) error {
+	req, err := http.NewRequestWithContext(
+		ctx, "POST", fmt.Sprintf("%s/%s", c.authHost, AccessTokenEndpoint),
+		strings.NewReader(payload.Encode()),
 	)
 	if err != nil {
 		return err
 	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
 	defer res.Body.Close()
 
 	decoder := json.NewDecoder(res.Body)
@@ -151,8 +682,14 @@ func (c *Client) SetAccessToken(authorizationCode string) error {
 		}
 
 		if access.TokenType == "Bearer" { // always true
-			c.access = access
-			return nil
+			access.IssuedAt = time.Now()
+			if access.RefreshToken == "" {
+				// refreshing may not rotate the refresh token itself
+				access.RefreshToken = c.currentAccess().RefreshToken
+			}
+
+			c.setAccess(access)
+			return c.saveAccess(access)
 		}
 	}
 
@@ -161,6 +698,23 @@ func (c *Client) SetAccessToken(authorizationCode string) error {
 	return authErr
 }
 
+// currentAccess returns the tokens the client is currently using. The returned value
+// must not be modified: the client swaps in a new `access` rather than editing it.
+func (c *Client) currentAccess() *access {
+	c.accessMu.RLock()
+	defer c.accessMu.RUnlock()
+
+	return c.access
+}
+
+// setAccess replaces the tokens the client uses.
+func (c *Client) setAccess(access *access) {
+	c.accessMu.Lock()
+	defer c.accessMu.Unlock()
+
+	c.access = access
+}
+
 // authError is used when there is an error during authentication such that the error
 // message can indicate that.
 type authError struct {
@@ -0,0 +1,504 @@
+package uber
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// oauthState returns the state parameter of an authorization URL.
+func oauthState(t *testing.T, authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u.Query().Get("state")
+}
+
+func TestOAuthState(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(testServerToken)
+
+	first, err := client.OAuth("client_id", "client_secret", "http://localhost"+Port, "profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := client.OAuth("client_id", "client_secret", "http://localhost"+Port, "profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstState, secondState := oauthState(t, first), oauthState(t, second)
+	if firstState == "" || firstState == State || firstState == secondState {
+		t.Fatalf("expected distinct random states, got %q and %q", firstState, secondState)
+	}
+
+	// both attempts are valid, but only once
+	for _, state := range []string{secondState, firstState} {
+		if err := client.CheckState(state); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.CheckState(firstState); !errors.Is(err, ErrUnknownState) {
+		t.Fatalf("expected a replayed state to be unknown, got %v", err)
+	}
+
+	var stateErr *StateError
+	if err := client.CheckState("forged"); !errors.As(err, &stateErr) || stateErr.State != "forged" {
+		t.Fatalf("expected a StateError for a foreign state, got %v", err)
+	}
+}
+
+func TestOAuthStateExpired(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(testServerToken)
+
+	authURL, err := client.OAuth("client_id", "client_secret", "http://localhost"+Port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := oauthState(t, authURL)
+	client.flows[state].created = time.Now().Add(-StateTTL - time.Second)
+
+	if err := client.CheckState(state); !errors.Is(err, ErrExpiredState) {
+		t.Fatalf("expected ErrExpiredState, got %v", err)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	t.Parallel()
+
+	authServer := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			req.ParseForm()
+			if req.PostForm.Get("code") != "auth_code" {
+				rw.WriteHeader(http.StatusBadRequest)
+				rw.Write([]byte(`{"error": "invalid_grant"}`))
+				return
+			}
+			rw.Write([]byte(`{"access_token": "bearer_token", "token_type": "Bearer",
+"expires_in": 2592000, "refresh_token": "refresh_token", "scope": "profile"}`))
+		},
+	))
+	defer authServer.Close()
+
+	client := NewClient(testServerToken, WithAuthHost(authServer.URL))
+	authURL, err := client.OAuth("client_id", "client_secret", "http://localhost"+Port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := client.Exchange(ctx, "forged", "auth_code"); !errors.Is(err, ErrUnknownState) {
+		t.Fatalf("expected ErrUnknownState, got %v", err)
+	}
+	if err := client.Exchange(ctx, oauthState(t, authURL), "auth_code"); err != nil {
+		t.Fatal(err)
+	}
+	if client.currentAccess().Token != testAccessToken {
+		t.Fatalf("expected token %s, got %s", testAccessToken, client.currentAccess().Token)
+	}
+}
+
+// newTestPKCEServer returns a token endpoint that only hands out a token if the code
+// verifier matches the challenge of the given authorization URL.
+func newTestPKCEServer(t *testing.T, authURL *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			req.ParseForm()
+
+			u, _ := url.Parse(*authURL)
+			challenge := u.Query().Get("code_challenge")
+			verifier := req.PostForm.Get("code_verifier")
+			sum := sha256.Sum256([]byte(verifier))
+
+			if method := u.Query().Get("code_challenge_method"); method != "S256" {
+				t.Errorf("expected S256 challenge, got %q", method)
+			}
+			if len(verifier) < 43 || len(verifier) > 128 {
+				t.Errorf("invalid verifier length %d", len(verifier))
+			}
+			if _, ok := req.PostForm["client_secret"]; ok {
+				t.Error("public client sent a client secret")
+			}
+
+			if base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+				rw.WriteHeader(http.StatusBadRequest)
+				rw.Write([]byte(`{"error": "invalid_grant"}`))
+				return
+			}
+			rw.Write([]byte(`{"access_token": "bearer_token", "token_type": "Bearer",
+"expires_in": 2592000, "refresh_token": "refresh_token", "scope": "profile"}`))
+		},
+	))
+}
+
+func TestPKCE(t *testing.T) {
+	t.Parallel()
+
+	var authURL string
+	authServer := newTestPKCEServer(t, &authURL)
+	defer authServer.Close()
+
+	client := NewClient(testServerToken, WithAuthHost(authServer.URL))
+
+	// a stale attempt's verifier doesn't match the latest challenge
+	stale, err := client.OAuth("client_id", "", "http://localhost"+Port, "profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err = client.OAuth("client_id", "", "http://localhost"+Port, "profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authURL == stale {
+		t.Fatal("expected a new challenge for every attempt")
+	}
+
+	err = client.Exchange(context.Background(), oauthState(t, stale), "auth_code")
+	var authErr *authError
+	if !errors.As(err, &authErr) || authErr.Err != "invalid_grant" {
+		t.Fatalf("expected invalid_grant, got %v", err)
+	}
+
+	if err := client.Exchange(context.Background(), oauthState(t, authURL), "auth_code"); err != nil {
+		t.Fatal(err)
+	}
+
+	// `SetAccessToken` uses the verifier of the latest attempt
+	authURL, err = client.OAuth("client_id", "", "http://localhost"+Port, "profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetAccessToken("auth_code"); err != nil {
+		t.Fatal(err)
+	}
+
+	// ...or of the attempt whose state was just checked
+	authURL, err = client.OAuth("client_id", "", "http://localhost"+Port, "profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CheckState(oauthState(t, authURL)); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetAccessToken("auth_code"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestTokenServer returns a token endpoint that hands out `testAccessToken` for any
+// authorization code.
+func newTestTokenServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			rw.Write([]byte(`{"access_token": "bearer_token", "token_type": "Bearer",
+"expires_in": 2592000, "refresh_token": "refresh_token", "scope": "profile"}`))
+		},
+	))
+}
+
+// testBrowser returns a `Browser` that follows the authorization URL straight to the
+// redirect URL, with the given state (the real one if empty).
+func testBrowser(t *testing.T, state string, redirects chan<- string) Browser {
+	return BrowserFunc(func(authURL string) error {
+		u, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		if state == "" {
+			state = u.Query().Get("state")
+		}
+
+		redirect := u.Query().Get("redirect_uri")
+		redirects <- redirect
+		go http.Get(redirect + "?" + url.Values{
+			"state": {state},
+			"code":  {"auth_code"},
+		}.Encode())
+
+		return nil
+	})
+}
+
+func TestAutOAuth(t *testing.T) {
+	t.Parallel()
+
+	authServer := newTestTokenServer()
+	defer authServer.Close()
+
+	// running the flow twice used to panic on the default mux
+	for i := 0; i < 2; i++ {
+		redirects := make(chan string, 1)
+
+		client := NewClient(testServerToken, WithAuthHost(authServer.URL))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := client.AutOAuthContext(
+			ctx, "client_id", "", "http://127.0.0.1/callback", []Scope{ScopeProfile},
+			WithBrowser(testBrowser(t, "", redirects)),
+		)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if client.currentAccess().Token != testAccessToken {
+			t.Fatalf("expected token %s, got %s", testAccessToken, client.currentAccess().Token)
+		}
+
+		redirect := <-redirects
+		u, _ := url.Parse(redirect)
+		if u.Port() == "" || u.Port() == "0" || u.Path != "/callback" {
+			t.Fatalf("expected the redirect to get an ephemeral port, got %s", redirect)
+		}
+		if _, err := http.Get(redirect); err == nil {
+			t.Fatal("expected the callback server to be shut down")
+		}
+	}
+}
+
+func TestAutOAuthListener(t *testing.T) {
+	t.Parallel()
+
+	authServer := newTestTokenServer()
+	defer authServer.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect := "http://" + l.Addr().String()
+
+	redirects := make(chan string, 1)
+
+	client := NewClient(testServerToken, WithAuthHost(authServer.URL))
+	if err := client.AutOAuthContext(
+		context.Background(), "client_id", "", redirect, nil,
+		WithListener(l), WithBrowser(testBrowser(t, "", redirects)),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := <-redirects; got != redirect {
+		t.Fatalf("expected redirect %s, got %s", redirect, got)
+	}
+}
+
+func TestAutOAuthForgedState(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(testServerToken)
+	err := client.AutOAuthContext(
+		context.Background(), "client_id", "", "http://localhost/", nil,
+		WithBrowser(testBrowser(t, "forged", make(chan string, 1))),
+	)
+	if !errors.Is(err, ErrUnknownState) {
+		t.Fatalf("expected ErrUnknownState, got %v", err)
+	}
+}
+
+func TestAutOAuthTimeout(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// the user never shows up
+	browser := BrowserFunc(func(string) error { return nil })
+
+	client := NewClient(testServerToken)
+	err := client.AutOAuthContext(
+		ctx, "client_id", "", "http://localhost/", nil, WithBrowser(browser),
+	)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// pastedRedirect returns a reader that writes the redirect URL for the authorization
+// URL printed to it, built by `paste`, once that URL has been written to `out`.
+type pastedRedirect struct {
+	out   bytes.Buffer
+	paste func(redirect, state string) string
+}
+
+func (p *pastedRedirect) Write(b []byte) (int, error) {
+	return p.out.Write(b)
+}
+
+func (p *pastedRedirect) Read(b []byte) (int, error) {
+	var authURL string
+	for _, field := range strings.Fields(p.out.String()) {
+		if strings.Contains(field, "/"+AccessCodeEndpoint+"?") {
+			authURL = field
+		}
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return 0, err
+	}
+
+	line := p.paste(u.Query().Get("redirect_uri"), u.Query().Get("state")) + "\n"
+	return copy(b, line), io.EOF
+}
+
+func TestAutOAuthHeadless(t *testing.T) {
+	t.Parallel()
+
+	authServer := newTestTokenServer()
+	defer authServer.Close()
+
+	browser := BrowserFunc(func(string) error {
+		t.Fatal("headless flow opened a browser")
+		return nil
+	})
+
+	for _, test := range []struct {
+		name  string
+		paste func(redirect, state string) string
+		err   error
+	}{
+		{
+			name: "url",
+			paste: func(redirect, state string) string {
+				return redirect + "?state=" + state + "&code=auth_code"
+			},
+		},
+		{
+			name: "query",
+			paste: func(redirect, state string) string {
+				return "  code=auth_code&state=" + state
+			},
+		},
+		{
+			name:  "code",
+			paste: func(redirect, state string) string { return "auth_code" },
+		},
+		{
+			name: "forged",
+			paste: func(redirect, state string) string {
+				return redirect + "?state=forged&code=auth_code"
+			},
+			err: ErrUnknownState,
+		},
+	} {
+		terminal := &pastedRedirect{paste: test.paste}
+
+		client := NewClient(testServerToken, WithAuthHost(authServer.URL))
+		err := client.AutOAuthContext(
+			context.Background(), "client_id", "", "https://example.com/callback", nil,
+			WithHeadless(terminal, terminal), WithBrowser(browser),
+		)
+		if !errors.Is(err, test.err) {
+			t.Fatalf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+		if test.err == nil && client.currentAccess().Token != testAccessToken {
+			t.Fatalf("%s: expected the token to be set", test.name)
+		}
+	}
+}
+
+func TestWithHeadlessDefaults(t *testing.T) {
+	t.Parallel()
+
+	config := new(authFlowConfig)
+	WithHeadless(nil, nil)(config)
+	if !config.headless || config.in != os.Stdin || config.out != os.Stdout {
+		t.Fatalf("expected the terminal, got %v and %v", config.in, config.out)
+	}
+}
+
+func TestParseRedirect(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := parseRedirect("https://example.com/callback?error=access_denied")
+	var authErr *authError
+	if !errors.As(err, &authErr) || authErr.Err != "access_denied" {
+		t.Fatalf("expected access_denied, got %v", err)
+	}
+
+	if _, _, err := parseRedirect("https://example.com/callback?state=x"); err == nil {
+		t.Fatal("expected an error without a code")
+	}
+	if _, _, err := parseRedirect(" \n"); err == nil {
+		t.Fatal("expected an error for empty input")
+	}
+}
+
+func TestOAuthScopes(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(testServerToken)
+
+	authURL, err := client.OAuthScopes(
+		"client_id", "", "http://localhost"+Port, ScopeProfile, ScopeHistory, "request",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	if scope := u.Query().Get("scope"); scope != "profile history request" {
+		t.Fatalf("expected scope %q, got %q", "profile history request", scope)
+	}
+
+	// scopes without a constant are passed on as they are
+	authURL, err = client.OAuth("client_id", "", "http://localhost"+Port, "new_scope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ = url.Parse(authURL)
+	if scope := u.Query().Get("scope"); scope != "new_scope" {
+		t.Fatalf("expected scope %q, got %q", "new_scope", scope)
+	}
+
+	for _, scope := range []Scope{"", "profile history"} {
+		_, err := client.OAuthScopes("client_id", "", "http://localhost"+Port, scope)
+		if err == nil {
+			t.Fatalf("expected an error for scope %q", scope)
+		}
+	}
+}
+
+func TestMissingScope(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			t.Errorf("unexpected request to %s", req.URL.Path)
+		},
+	))
+	defer server.Close()
+
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
+	client.setAccess(&access{Token: testAccessToken, Scope: "profile history_lite"})
+
+	if !client.HasScope(ScopeProfile) || client.HasScope(ScopeHistory) {
+		t.Fatalf("unexpected granted scopes %v", client.GrantedScopes())
+	}
+
+	_, err := client.PostRequest("a1111c8c", 37.775, -122.417, 37.786, -122.402, "")
+	var missing *ErrMissingScope
+	if !errors.As(err, &missing) || missing.Scope != ScopeRequest {
+		t.Fatalf("expected the request scope to be missing, got %v", err)
+	}
+
+	client.setAccess(&access{Token: testAccessToken, Scope: "profile"})
+	_, err = client.GetUserActivity(0, 10)
+	if !errors.As(err, &missing) || missing.Scope != ScopeHistory {
+		t.Fatalf("expected the history scope to be missing, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package uber
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// callbackShutdownTimeout is how long a `callbackServer` waits for in-flight requests
+// when shutting down.
+const callbackShutdownTimeout = 5 * time.Second
+
+// callbackServer is a short lived HTTP server that receives a single redirect from Uber,
+// eg: the OAuth callback. It has its own `http.ServeMux` and `http.Server`, so it never
+// touches `http.DefaultServeMux` and can be started any number of times.
+type callbackServer struct {
+	// The redirect URL, with the port the server actually listens on
+	url string
+
+	server *http.Server
+	result chan error
+	once   sync.Once
+}
+
+// newCallbackServer starts serving `redirect` and calls `handle` with the query of the
+// first request made to it. Subsequent requests are turned away.
+//
+// If `l` is nil, the server listens on the host and port of `redirect`. A loopback
+// redirect URL without a port (or with port 0) gets an ephemeral port, and `url` is
+// updated to match; for other hosts, `Port` is used.
+func newCallbackServer(
+	ctx context.Context, redirect string, l net.Listener,
+	handle func(context.Context, url.Values) error,
+) (*callbackServer, error) {
+	u, err := url.Parse(redirect)
+	if err != nil {
+		return nil, err
+	}
+
+	loopback := isLoopback(u.Hostname())
+	if l == nil {
+		addr := u.Host
+		switch {
+		case loopback && (u.Port() == "" || u.Port() == "0"):
+			addr = net.JoinHostPort(u.Hostname(), "0")
+		case !loopback && u.Port() == "":
+			addr = Port
+		case !loopback:
+			addr = ":" + u.Port()
+		}
+
+		if l, err = net.Listen("tcp", addr); err != nil {
+			return nil, err
+		}
+	}
+
+	if loopback && (u.Port() == "" || u.Port() == "0") {
+		_, port, err := net.SplitHostPort(l.Addr().String())
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	s := &callbackServer{
+		url:    u.String(),
+		result: make(chan error, 1),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		// a pattern ending in "/" matches everything below it (eg: "/favicon.ico")
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+
+		handled := false
+		s.once.Do(func() {
+			handled = true
+
+			err := handle(ctx, r.URL.Query())
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "%v\n", err)
+			} else {
+				fmt.Fprintf(w, `<script type="text/javascript">close()</script>
+you may close this webpage`)
+			}
+
+			s.result <- err
+		})
+		if !handled {
+			http.Error(w, "this request has already been handled", http.StatusGone)
+		}
+	})
+
+	s.server = &http.Server{Handler: mux}
+	go func() {
+		if err := s.server.Serve(l); err != http.ErrServerClosed {
+			s.once.Do(func() { s.result <- err })
+		}
+	}()
+
+	return s, nil
+}
+
+// wait blocks until the callback has been handled or `ctx` is done, and then shuts the
+// server down.
+func (s *callbackServer) wait(ctx context.Context) error {
+	var err error
+	select {
+	case err = <-s.result:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.close()
+
+	return err
+}
+
+// close shuts the server down gracefully, letting the callback's response go out.
+func (s *callbackServer) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), callbackShutdownTimeout)
+	defer cancel()
+
+	if s.server.Shutdown(ctx) != nil {
+		s.server.Close()
+	}
+}
+
+// isLoopback reports whether `host` refers to the local machine.
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -1,13 +1,18 @@
 package uber
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Client stores the tokens needed to access the Uber api.
@@ -30,41 +35,155 @@ type Client struct {
 	// memoize it here, as it will always be used.
 	httpClient *http.Client
 
+	// The api and auth hosts this client talks to, and the value of the User-Agent
+	// header it sends, if any (see `ClientOption`).
+	apiHost   string
+	authHost  string
+	userAgent string
+	timeout   time.Duration
+
+	// In sandbox mode, the ride request endpoints are sent to `sandboxHost` so that no
+	// real car is dispatched.
+	sandbox     bool
+	sandboxHost string
+
+	// How long `WaitForStatus` waits between polls (see `WithBackoff`), and how often
+	// `WatchRequest` polls (see `WithPollInterval`).
+	backoff      Backoff
+	pollInterval time.Duration
+
+	// The rides booked for each idempotency key (see `WithIdempotencyKey`).
+	idempotencyStore IdempotencyStore
+
 	// contains further authentication information for Uber OAuth flow.
 	*auth
+
+	// Where the client persists its tokens, if anywhere (see `Client.SetTokenStore`),
+	// and the user under which they are stored.
+	tokenStore       TokenStore
+	tokenUser        string
+	onTokenSaveError func(error)
+
+	// Authorization attempts started with `OAuth`, keyed by their state, and the
+	// latest of them.
+	flows      map[string]*flow
+	latestFlow *flow
+	flowsMu    sync.Mutex
+
+	// accessMu guards `access`, which is replaced whenever the token is refreshed.
+	// refreshMu makes sure only one goroutine refreshes the token at a time.
+	accessMu  sync.RWMutex
+	refreshMu sync.Mutex
 }
 
+// tokenRefreshLeeway is how long before its expiry an access token gets refreshed.
+const tokenRefreshLeeway = 5 * time.Minute
+
 // NewClient creates a new client. The serverToken is your API token provided by Uber.
 // When accessing a user's profile or activity a serverToken is not enough and an
 // accessToken must be specified with the correct scope.
 // To access those endpoints, use `*Client.OAuth()`
-func NewClient(serverToken string) *Client {
-	return &Client{
-		serverToken: serverToken,
-		access:      new(access),
-		httpClient:  new(http.Client),
+//
+// The options override the client's defaults, eg: the hosts it talks to.
+func NewClient(serverToken string, opts ...ClientOption) *Client {
+	c := &Client{
+		serverToken:  serverToken,
+		access:       new(access),
+		httpClient:   new(http.Client),
+		apiHost:      UberAPIHost,
+		authHost:     AuthHost,
+		sandboxHost:  strings.TrimSuffix(UberSandboxAPIHost, "/sandbox"),
+		backoff:      DefaultBackoff,
+		pollInterval: DefaultPollInterval,
+
+		idempotencyStore: NewMemoryIdempotencyStore(),
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.timeout > 0 {
+		hc := *c.httpClient
+		hc.Timeout = c.timeout
+		c.httpClient = &hc
+	}
+
+	return c
 }
 
 // get helps facilitate all the get requests to the Uber api.
-// Takes the endpoint, the query parameters, whether or not oauth should be used
-// and the data structure that the JSON response should be unmarshalled into.
-func (c *Client) get(endpoint string, payload uberAPIReq, oauth bool, out uberAPIResp) error {
-	return c.httpReqDo("GET", endpoint, payload, oauth, out)
+// Takes the context, the endpoint, the query parameters, whether or not oauth should
+// be used and the data structure that the JSON response should be unmarshalled into.
+func (c *Client) get(
+	ctx context.Context, endpoint string, payload uberAPIReq, oauth bool, out uberAPIResp,
+) error {
+	return c.httpReqDo(ctx, "GET", endpoint, payload, oauth, out)
 }
 
+// httpReqDo executes a request against the Uber api. The context is attached to the
+// outgoing request, so cancelling it or letting its deadline pass aborts the call.
+// For methods that carry a body (POST, PUT and PATCH) the payload is sent as JSON;
+// for every other method it is encoded in the query string.
 func (c *Client) httpReqDo(
-	method, endpoint string, payload uberAPIReq, oauth bool, out uberAPIResp,
+	ctx context.Context, method, endpoint string, payload uberAPIReq, oauth bool,
+	out uberAPIResp,
 ) error {
-	url, err := c.generateRequestURL(UberAPIHost, endpoint, payload)
-	if err != nil {
-		return err
+	return c.httpReqDoHost(ctx, c.host(endpoint), method, endpoint, payload, oauth, out)
+}
+
+// httpReqDoHost is like `httpReqDo` but sends the request to `host` rather than the
+// one the client would pick for `endpoint`.
+func (c *Client) httpReqDoHost(
+	ctx context.Context, host, method, endpoint string, payload uberAPIReq, oauth bool,
+	out uberAPIResp,
+) error {
+	var (
+		url  string
+		body []byte
+		err  error
+	)
+	if methodHasBody(method) {
+		if url, err = c.generateRequestURL(host, endpoint, nil); err != nil {
+			return err
+		}
+		if body, err = c.generateRequestBody(payload); err != nil {
+			return err
+		}
+	} else {
+		if url, err = c.generateRequestURL(host, endpoint, payload); err != nil {
+			return err
+		}
 	}
 
-	res, err := c.sendRequestWithAuthorization(method, url, oauth)
+	var token string
+	if oauth {
+		token, err = c.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	res, err := c.sendRequestWithAuthorization(ctx, method, url, body, oauth)
 	if err != nil {
 		return err
 	}
+
+	// the token may have been revoked or have expired without us knowing, so refresh it
+	// and try once more
+	if oauth && res.StatusCode == http.StatusUnauthorized &&
+		c.currentAccess().RefreshToken != "" {
+		res.Body.Close()
+
+		if err := c.refreshAccessToken(ctx, token); err != nil {
+			return err
+		}
+
+		res, err = c.sendRequestWithAuthorization(ctx, method, url, body, oauth)
+		if err != nil {
+			return err
+		}
+	}
 	defer res.Body.Close()
 
 	decoder := json.NewDecoder(res.Body)
@@ -72,19 +191,31 @@ func (c *Client) httpReqDo(
 	// If the status code is non-2xx, generate the error
 	switch {
 	case res.StatusCode == http.StatusNotFound:
-		// should never, ever happen because we specify the endpoints
+		// either the endpoint doesn't exist, or what it is about doesn't, eg: the
+		// current ride request of a user who has none
 		return &uberError{
-			Message: fmt.Sprintf("Endpoint '%s' not found.", endpoint),
+			Message:    fmt.Sprintf("Endpoint '%s' not found.", endpoint),
+			statusCode: res.StatusCode,
 		}
 	case res.StatusCode >= 300:
-		decoder = json.NewDecoder(res.Body)
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			return err
+		}
 
 		// no good way to do this with `http.Status...` codes ;o
-		uberErr := new(uberError)
-		if err := decoder.Decode(uberErr); err != nil {
+		uberErr := &uberError{statusCode: res.StatusCode}
+		if err := json.Unmarshal(body, uberErr); err != nil {
 			return err
 		}
 
+		// eg: a ride request the user has to confirm surge pricing for
+		if res.StatusCode == http.StatusConflict {
+			if surgeErr := newSurgeRequiredError(*uberErr, body); surgeErr != nil {
+				return surgeErr
+			}
+		}
+
 		// the case where the Uber api didn't provide an UberError in the response
 		if uberErr.Message == "" && uberErr.Code == "" {
 			return errors.New("uber: an unidentified error occured")
@@ -93,6 +224,11 @@ func (c *Client) httpReqDo(
 		return *uberErr
 	}
 
+	// eg: `DeleteRequest` doesn't expect anything back (the api answers 204)
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
 	err = decoder.Decode(out)
 	if err != nil {
 		return err
@@ -101,27 +237,79 @@ func (c *Client) httpReqDo(
 	return nil
 }
 
-// sendRequestWithAuthorization sends an HTTP GET request with an Authorization
+// sendRequestWithAuthorization sends an HTTP request with an Authorization
 // field in the header containing the Client's access token (bearer token) if
-// the oauth parameter is true and the server token (api token) if not.
+// the oauth parameter is true and the server token (api token) if not. A non-nil
+// body is sent as JSON.
 func (c *Client) sendRequestWithAuthorization(
-	method, url string, oauth bool,
+	ctx context.Context, method, url string, body []byte, oauth bool,
 ) (*http.Response, error) {
-	req, err := http.NewRequest("GET", url, nil)
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
 		return nil, err
 	}
 
 	authStr := fmt.Sprintf("Token %s", c.serverToken)
 	if oauth {
-		authStr = fmt.Sprintf("Bearer %s", c.Token)
+		authStr = fmt.Sprintf("Bearer %s", c.currentAccess().Token)
 	}
 
 	req.Header.Set("authorization", authStr)
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	return c.httpClient.Do(req)
 }
 
+// host returns the host `endpoint` should be requested from.
+func (c *Client) host(endpoint string) string {
+	if c.sandbox && (strings.HasPrefix(endpoint, RequestEndpoint) ||
+		strings.HasPrefix(endpoint, "sandbox/")) {
+		return c.sandboxHost
+	}
+
+	return c.apiHost
+}
+
+// versionedHost returns the host to use for a version of the api other than `Version`.
+// The client's host is expected to end with `Version`, which is swapped for `version`.
+func (c *Client) versionedHost(endpoint, version string) string {
+	return strings.TrimSuffix(c.host(endpoint), "/"+Version) + "/" + version
+}
+
+// accessToken returns the access token to use for a request, refreshing it first if it
+// is about to expire.
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	access := c.currentAccess()
+	if access.RefreshToken != "" && access.expiresWithin(tokenRefreshLeeway) {
+		if err := c.refreshAccessToken(ctx, access.Token); err != nil {
+			return "", err
+		}
+	}
+
+	return c.currentAccess().Token, nil
+}
+
+// methodHasBody reports whether requests with the given HTTP method send their
+// payload in the body rather than in the query string.
+func methodHasBody(method string) bool {
+	switch method {
+	case "POST", "PUT", "PATCH":
+		return true
+	}
+
+	return false
+}
+
 // generateRequestURL returns the appropriate a request url to the Uber api based on
 // the specified endpoint and the data passed in
 func (c *Client) generateRequestURL(base, endpoint string, data uberAPIReq) (string, error) {
@@ -147,7 +335,40 @@ func (c *Client) generateRequestURL(base, endpoint string, data uberAPIReq) (str
 // generateRequestURLHelper recursively checks `val` to generate the payload. Should
 // be used with caution. Only `Client.generateRequestURL` calls this.
 func (c *Client) generateRequestURLHelper(val reflect.Value) (url.Values, error) {
+	fields, err := c.requestFields(val)
+	if err != nil {
+		return nil, err
+	}
+
 	payload := make(url.Values)
+	for k, v := range fields {
+		payload.Add(k, fmt.Sprintf("%v", v))
+	}
+
+	return payload, nil
+}
+
+// generateRequestBody returns the JSON body for a request to the Uber api based on
+// the data passed in. The same `query` struct tags that drive
+// `Client.generateRequestURL` name the JSON fields.
+func (c *Client) generateRequestBody(data uberAPIReq) ([]byte, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	fields, err := c.requestFields(reflect.ValueOf(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(fields)
+}
+
+// requestFields recursively checks `val` and collects the values of its tagged fields,
+// keyed by tag name. Numbers keep their type so that they can be marshalled into JSON
+// as such. Pointer fields are left out when nil, which lets numbers be optional.
+func (c *Client) requestFields(val reflect.Value) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
 	for i := 0; i < val.NumField(); i++ {
 		fieldName := val.Type().Field(i).Name
 		queryTag := strings.Split(val.Type().Field(i).Tag.Get("query"), ",")
@@ -156,14 +377,24 @@ func (c *Client) generateRequestURLHelper(val reflect.Value) (url.Values, error)
 			continue
 		}
 
+		field := val.Field(i)
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				continue
+			}
+			field = field.Elem()
+		}
+
 		var v interface{}
-		switch val.Field(i).Kind() {
+		switch field.Kind() {
 		case reflect.Int:
-			v = val.Field(i).Int()
+			v = field.Int()
 		case reflect.Float64:
-			v = val.Field(i).Float()
+			v = field.Float()
+		case reflect.Bool:
+			v = field.Bool()
 		case reflect.String:
-			v = val.Field(i).String()
+			v = field.String()
 			if len(queryTag) > 1 && queryTag[1] == "required" {
 				// cannot be required and empty
 				if v == "" {
@@ -171,29 +402,26 @@ func (c *Client) generateRequestURLHelper(val reflect.Value) (url.Values, error)
 				}
 			}
 		case reflect.Struct:
-			supPayload, err := c.generateRequestURLHelper(val.Field(i))
+			supFields, err := c.requestFields(field)
 			if err != nil {
 				return nil, err
 			}
 
-			// avoids nil field on struct (eg res)
-			if len(supPayload) == 0 {
-				continue
+			for k, va := range supFields {
+				fields[k] = va
 			}
 
-			for k, va := range supPayload {
-				payload.Add(k, va[0])
-			}
+			continue
 		default:
 			return nil, fmt.Errorf("%s is invalid", fieldName)
 		}
 
 		if v != "" && queryTag[0] != "" {
-			payload.Add(queryTag[0], fmt.Sprintf("%v", v))
+			fields[queryTag[0]] = v
 		}
 	}
 
-	return payload, nil
+	return fields, nil
 }
 
 // Shell data definitions used to document that `Client.generateRequestURL` takes a
@@ -12,20 +12,34 @@
 // 1. `uber.go` contains all the exported types (that directly reflect some json object
 // the Uber API returns) that this package contains. This file also has global constants
 // and variables. Finally, `uber.go` contains a few error types that are used in the
-// package itself.
+// package itself. `status.go` has the `RideStatus` state machine.
 //
 // 2. `client.go` contains the definition of `Client` (the type with which the user
 // interacts). Aside from the constructor for the client, this file contains low-level
-// functions for generating and executing HTTP requests to the Uber API.
+// functions for generating and executing HTTP requests to the Uber API. The options
+// `NewClient` takes are in `options.go`.
 //
 // 3. `endpoints.go` contains the definitions of the exported methods on `Client` that
-// call the Uber API endpoints. This is the meat of this package's API.
+// call the Uber API endpoints. This is the meat of this package's API. The methods that
+// only work in sandbox mode are in `sandbox.go`, and `history.go` has an iterator over
+// a user's whole history. `ridespec.go` has the `RideSpec` that describes a ride to
+// estimate or book, and checks it. `wait.go` and `watch.go` poll a ride request, until
+// it reaches a status or as it changes, and `webhook.go` receives the events Uber pushes
+// instead. `idempotency.go` keeps retried ride requests from booking a second ride, and
+// `surge.go` has them confirm surge pricing.
 //
 // 4. `auth.go` contains all the functions related to authorizing your app.
+// `callback.go` contains the short lived server that receives Uber's redirects.
 //
 // 5. `requests.go` contains a plethora of unexported types needed to make requests and
 // parse responses.
 //
+// 6. `tokenstore.go` contains the `TokenStore` interface, used to persist OAuth tokens,
+// and its implementations. `tokencrypt.go` has the one that encrypts tokens at rest.
+//
+// The `export` package, built on top of this one, writes a user's whole history to CSV,
+// JSON Lines or GeoJSON, and the `analytics` package aggregates it.
+//
 // TODO
 //
 // Write tests.
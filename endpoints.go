@@ -1,57 +1,165 @@
 package uber
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrNoCurrentRequest is returned by `GetCurrentRequest` when the user isn't on a ride.
+var ErrNoCurrentRequest = errors.New("uber: the user has no current ride request")
 
 //
 // the `Client` API
 //
+// Every method that hits the Uber api has a `...Context` variant that takes a
+// `context.Context` as its first argument. The context is attached to the underlying
+// HTTP request, so it can be used to set deadlines on, or cancel, calls to the api.
+// The variants without a context use `context.Background()`.
+//
+// Methods that act on behalf of a user return an `*ErrMissingScope` without calling the
+// api if the user hasn't granted the scope they need.
+//
 
 // PostRequest allows a ride to be requested on behalf of an Uber user given
 // their desired product, start, and end locations.
+//
+// Deprecated: use `RequestRide`, which takes the ride as a `RideSpec`.
 func (c *Client) PostRequest(
 	productID string, startLat, startLon, endLat, endLon float64, surgeConfirmationID string,
 ) (*Request, error) {
-	payload := requestReq{
-		productID:           productID,
-		startLatitude:       startLat,
-		startLongitude:      startLon,
-		endLatitude:         endLat,
-		endLongitude:        endLon,
-		surgeConfirmationID: surgeConfirmationID,
+	return c.PostRequestContext(
+		context.Background(),
+		productID, startLat, startLon, endLat, endLon, surgeConfirmationID,
+	)
+}
+
+// PostRequestContext is like `PostRequest` but takes a context.
+//
+// Deprecated: use `RequestRide`, which takes the ride as a `RideSpec`.
+func (c *Client) PostRequestContext(
+	ctx context.Context,
+	productID string, startLat, startLon, endLat, endLon float64, surgeConfirmationID string,
+) (*Request, error) {
+	return c.RequestRide(ctx, RideSpec{
+		ProductID:           productID,
+		Start:               At(startLat, startLon),
+		End:                 At(endLat, endLon),
+		SurgeConfirmationID: surgeConfirmationID,
+	})
+}
+
+// RequestRide allows a ride to be requested on behalf of an Uber user. The ride is
+// checked with `RideSpec.Validate` first. It can carry the fare of an estimate (see
+// `EstimateRequest`) so that the ride is booked at that price.
+//
+// Booking is made idempotent by giving it a context from `WithIdempotencyKey`; if the
+// ride is booked but can't be saved in the client's `IdempotencyStore`, it is returned
+// with the error.
+func (c *Client) RequestRide(ctx context.Context, spec RideSpec) (*Request, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
 	}
-	request := new(requestResp)
+	if err := c.requireScope(ScopeRequest); err != nil {
+		return nil, err
+	}
+
+	return c.bookRide(ctx, spec.requestReq())
+}
 
-	if err := c.httpReqDo("POST", RequestEndpoint, payload, true, request); err != nil {
+// EstimateRequest gets the upfront fare, pickup estimate and trip estimate of a ride
+// before it is booked. Unlike `GetPrices`, which only gives a range, the fare is the
+// price of the ride if it is booked with `RequestRide` and the fare's ID, before it
+// expires. The ride needs a start, but no product or destination.
+func (c *Client) EstimateRequest(ctx context.Context, spec RideSpec) (*RideEstimate, error) {
+	if err := spec.validatePlaces(); err != nil {
+		return nil, err
+	}
+	if err := c.requireScope(ScopeRequest); err != nil {
 		return nil, err
 	}
 
-	return &request.Request, nil
+	estimate := new(RideEstimate)
+	err := c.httpReqDoHost(
+		ctx, c.versionedHost(RequestEstimateEndpoint, FareVersion),
+		"POST", RequestEstimateEndpoint, spec.requestEstimateReq(), true, estimate,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return estimate, nil
 }
 
 // GetRequest gets the real time status of an ongoing trip that was created using the Ride
 // Request endpoint.
 func (c *Client) GetRequest(requestID string) (*Request, error) {
+	return c.GetRequestContext(context.Background(), requestID)
+}
+
+// GetRequestContext is like `GetRequest` but takes a context.
+func (c *Client) GetRequestContext(ctx context.Context, requestID string) (*Request, error) {
+	if err := c.requireScope(ScopeRequest); err != nil {
+		return nil, err
+	}
+
 	request := new(Request)
-	err := c.get(fmt.Sprintf("%s/%s", RequestEndpoint, requestID), nil, true, request)
+	err := c.get(ctx, fmt.Sprintf("%s/%s", RequestEndpoint, requestID), nil, true, request)
 	if err != nil {
 		return nil, err
 	}
 
 	return request, nil
+}
+
+// GetCurrentRequest gets the ride request the user is currently on, whichever app it
+// was made with. It returns `ErrNoCurrentRequest` if they are on none.
+func (c *Client) GetCurrentRequest(ctx context.Context) (*Request, error) {
+	if err := c.requireScope(ScopeRequest, ScopeAllTrips); err != nil {
+		return nil, err
+	}
+
+	request := new(Request)
+	err := c.get(ctx, CurrentRequestEndpoint, nil, true, request)
+	if statusCode(err) == http.StatusNotFound {
+		return nil, ErrNoCurrentRequest
+	} else if err != nil {
+		return nil, err
+	}
 
+	return request, nil
 }
 
 // DeleteRequest cancels an ongoing `Request` on behalf of a rider.
 func (c *Client) DeleteRequest(requestID string) error {
+	return c.DeleteRequestContext(context.Background(), requestID)
+}
+
+// DeleteRequestContext is like `DeleteRequest` but takes a context.
+func (c *Client) DeleteRequestContext(ctx context.Context, requestID string) error {
+	if err := c.requireScope(ScopeRequest); err != nil {
+		return err
+	}
+
 	return c.httpReqDo(
-		"DELETE", fmt.Sprintf("%s/%s", RequestEndpoint, requestID), nil, true, nil,
+		ctx, "DELETE", fmt.Sprintf("%s/%s", RequestEndpoint, requestID), nil, true, nil,
 	)
 }
 
 // GetRequestMap get a map with a visual representation of a `Request`.
 func (c *Client) GetRequestMap(requestID string) (string, error) {
+	return c.GetRequestMapContext(context.Background(), requestID)
+}
+
+// GetRequestMapContext is like `GetRequestMap` but takes a context.
+func (c *Client) GetRequestMapContext(ctx context.Context, requestID string) (string, error) {
+	if err := c.requireScope(ScopeRequest); err != nil {
+		return "", err
+	}
+
 	mapResp := new(requestMapResp)
-	err := c.get(fmt.Sprintf("%s/%s/map", RequestEndpoint, requestID), nil, true, mapResp)
+	err := c.get(ctx, fmt.Sprintf("%s/%s/map", RequestEndpoint, requestID), nil, true, mapResp)
 	if err != nil {
 		return "", err
 	}
@@ -64,13 +172,18 @@ func (c *Client) GetRequestMap(requestID string) (string, error) {
 // each product, and lists the products in the proper display order.
 // https://developer.uber.com/v1/endpoints/#product-types
 func (c *Client) GetProducts(lat, lon float64) ([]*Product, error) {
+	return c.GetProductsContext(context.Background(), lat, lon)
+}
+
+// GetProductsContext is like `GetProducts` but takes a context.
+func (c *Client) GetProductsContext(ctx context.Context, lat, lon float64) ([]*Product, error) {
 	payload := productsReq{
 		latitude:  lat,
 		longitude: lon,
 	}
 	products := new(productsResp)
 
-	if err := c.get(ProductEndpoint, payload, false, products); err != nil {
+	if err := c.get(ctx, ProductEndpoint, payload, false, products); err != nil {
 		return nil, err
 	}
 
@@ -87,6 +200,13 @@ func (c *Client) GetProducts(lat, lon float64) ([]*Product, error) {
 // estimate already factors in this multiplier.
 // https://developer.uber.com/v1/endpoints/#price-estimates
 func (c *Client) GetPrices(startLat, startLon, endLat, endLon float64) ([]*Price, error) {
+	return c.GetPricesContext(context.Background(), startLat, startLon, endLat, endLon)
+}
+
+// GetPricesContext is like `GetPrices` but takes a context.
+func (c *Client) GetPricesContext(
+	ctx context.Context, startLat, startLon, endLat, endLon float64,
+) ([]*Price, error) {
 	payload := pricesReq{
 		startLatitude:  startLat,
 		startLongitude: startLon,
@@ -95,7 +215,7 @@ func (c *Client) GetPrices(startLat, startLon, endLat, endLon float64) ([]*Price
 	}
 	prices := new(pricesResp)
 
-	if err := c.get(PriceEndpoint, payload, false, prices); err != nil {
+	if err := c.get(ctx, PriceEndpoint, payload, false, prices); err != nil {
 		return nil, err
 	}
 
@@ -109,6 +229,13 @@ func (c *Client) GetPrices(startLat, startLon, endLat, endLon float64) ([]*Price
 // additional experience customization.
 func (c *Client) GetTimes(
 	startLat, startLon float64, uuid, productID string,
+) ([]*Time, error) {
+	return c.GetTimesContext(context.Background(), startLat, startLon, uuid, productID)
+}
+
+// GetTimesContext is like `GetTimes` but takes a context.
+func (c *Client) GetTimesContext(
+	ctx context.Context, startLat, startLon float64, uuid, productID string,
 ) ([]*Time, error) {
 	payload := timesReq{
 		startLatitude:  startLat,
@@ -118,7 +245,7 @@ func (c *Client) GetTimes(
 	}
 	times := new(timesResp)
 
-	if err := c.get(TimeEndpoint, payload, false, times); err != nil {
+	if err := c.get(ctx, TimeEndpoint, payload, false, times); err != nil {
 		return nil, err
 	}
 
@@ -129,13 +256,39 @@ func (c *Client) GetTimes(
 // will include pickup locations and times, dropoff locations and times, the distance
 // of past requests, and information about which products were requested.
 func (c *Client) GetUserActivity(offset, limit int) (*UserActivity, error) {
+	return c.GetUserActivityContext(context.Background(), offset, limit)
+}
+
+// GetUserActivityContext is like `GetUserActivity` but takes a context.
+func (c *Client) GetUserActivityContext(
+	ctx context.Context, offset, limit int,
+) (*UserActivity, error) {
+	return c.GetUserActivityVersion(ctx, HistoryV1, offset, limit)
+}
+
+// GetUserActivityVersion is like `GetUserActivityContext` but gets the activity from
+// the given version of the endpoint, eg: `HistoryV12`, whose trips have more
+// information.
+func (c *Client) GetUserActivityVersion(
+	ctx context.Context, version HistoryVersion, offset, limit int,
+) (*UserActivity, error) {
+	if err := c.requireScope(ScopeHistory, ScopeHistoryLite); err != nil {
+		return nil, err
+	}
+
 	payload := historyReq{
 		offset: offset,
 		limit:  limit,
 	}
-	userActivity := new(UserActivity)
+	userActivity := &UserActivity{Version: version}
 
-	if err := c.get(TimeEndpoint, payload, true, userActivity); err != nil {
+	host := c.host(HistoryEndpoint)
+	if version != HistoryV1 {
+		host = c.versionedHost(HistoryEndpoint, string(version))
+	}
+
+	err := c.httpReqDoHost(ctx, host, "GET", HistoryEndpoint, payload, true, userActivity)
+	if err != nil {
 		return nil, err
 	}
 
@@ -145,9 +298,18 @@ func (c *Client) GetUserActivity(offset, limit int) (*UserActivity, error) {
 // GetUserProfile returns information about the Uber user that has authorized with
 // the application.
 func (c *Client) GetUserProfile() (*User, error) {
+	return c.GetUserProfileContext(context.Background())
+}
+
+// GetUserProfileContext is like `GetUserProfile` but takes a context.
+func (c *Client) GetUserProfileContext(ctx context.Context) (*User, error) {
+	if err := c.requireScope(ScopeProfile); err != nil {
+		return nil, err
+	}
+
 	user := new(User)
 
-	if err := c.get(UserEndpoint, nil, true, user); err != nil {
+	if err := c.get(ctx, UserEndpoint, nil, true, user); err != nil {
 		return nil, err
 	}
 
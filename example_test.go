@@ -1,6 +1,7 @@
 package uber_test
 
 import (
+	"context"
 	"fmt"
 
 	uber "github.com/r-medina/go-uber"
@@ -42,9 +43,9 @@ func ExampleClient_requests() {
 // Uber's OAuth 2.0 flow requires the user go to URL they provide. You can generate this
 // URL programatically by calling `client.OAuth`. After the user goes to URL and grants
 // your application permissions, you need to figure out a way for the user to input the
-// second argument of the URL to which they are redirected (ie: the `code`
-// argument`AuthCode` in `RedirectUrl/?state=go-uber&code=AuthCode`). You then need to set
-// the access token with `client.SetAccessToken`.
+// arguments of the URL to which they are redirected (ie: the `state` and `code`
+// arguments in `RedirectUrl/?state=State&code=AuthCode`). You then need to check the
+// state and set the access token with `client.Exchange`.
 func ExampleClient_auth() {
 	url, err := client.OAuth(
 		"your client_id", "your client_secret", "your redirect_url", "profile",
@@ -58,7 +59,7 @@ func ExampleClient_auth() {
 
 	fmt.Printf("Please go to %+v to authorize this app.\n", url)
 
-	client.SetAccessToken("AuthCode")
+	client.Exchange(context.Background(), "State", "AuthCode")
 }
 
 // Alternatively, you can automate the whole thing with the `client.AutoAuth`. This opens
@@ -0,0 +1,177 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	uber "github.com/r-medina/go-uber"
+)
+
+// Column is a column of the CSV written by a `CSVEncoder`.
+type Column struct {
+	// The column's header
+	// eg: "request_id"
+	Name string
+
+	// Formats the column's value for a trip
+	Value func(trip *uber.Trip) string
+}
+
+// The columns a `CSVEncoder` can write. Times are formatted as RFC 3339, in UTC, and
+// the values of a missing location are left empty.
+var (
+	ColumnUUID           = Column{"uuid", func(t *uber.Trip) string { return t.Uuid }}
+	ColumnRequestID      = Column{"request_id", func(t *uber.Trip) string { return t.RequestID }}
+	ColumnProductID      = Column{"product_id", func(t *uber.Trip) string { return t.ProductID }}
+	ColumnStatus         = Column{"status", func(t *uber.Trip) string { return string(t.Status) }}
+	ColumnDistance       = Column{"distance", func(t *uber.Trip) string { return formatFloat(t.Distance) }}
+	ColumnRequestTime    = Column{"request_time", func(t *uber.Trip) string { return formatTime(t.RequestedAt) }}
+	ColumnStartTime      = Column{"start_time", func(t *uber.Trip) string { return formatTime(t.StartedAt) }}
+	ColumnEndTime        = Column{"end_time", func(t *uber.Trip) string { return formatTime(t.EndedAt) }}
+	ColumnStartCity      = Column{"start_city", startCity}
+	ColumnStartAddress   = Column{"start_address", locationField(start, address)}
+	ColumnStartLatitude  = Column{"start_latitude", locationField(start, latitude)}
+	ColumnStartLongitude = Column{"start_longitude", locationField(start, longitude)}
+	ColumnEndAddress     = Column{"end_address", locationField(end, address)}
+	ColumnEndLatitude    = Column{"end_latitude", locationField(end, latitude)}
+	ColumnEndLongitude   = Column{"end_longitude", locationField(end, longitude)}
+)
+
+// DefaultColumns are the columns a `CSVEncoder` writes unless it's given others.
+var DefaultColumns = []Column{
+	ColumnRequestID, ColumnProductID, ColumnStatus, ColumnDistance,
+	ColumnRequestTime, ColumnStartTime, ColumnEndTime,
+	ColumnStartLatitude, ColumnStartLongitude, ColumnEndLatitude, ColumnEndLongitude,
+}
+
+var columns = map[string]Column{}
+
+func init() {
+	for _, column := range []Column{
+		ColumnUUID, ColumnRequestID, ColumnProductID, ColumnStatus, ColumnDistance,
+		ColumnRequestTime, ColumnStartTime, ColumnEndTime, ColumnStartCity,
+		ColumnStartAddress, ColumnStartLatitude, ColumnStartLongitude,
+		ColumnEndAddress, ColumnEndLatitude, ColumnEndLongitude,
+	} {
+		columns[column.Name] = column
+	}
+}
+
+// ColumnsByName returns the columns with the given names, eg: to read them from a
+// configuration file.
+func ColumnsByName(names ...string) ([]Column, error) {
+	cols := make([]Column, len(names))
+	for i, name := range names {
+		column, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("export: unknown column %q", name)
+		}
+		cols[i] = column
+	}
+
+	return cols, nil
+}
+
+// CSVEncoder writes trips as CSV, with a header.
+type CSVEncoder struct {
+	w       *csv.Writer
+	columns []Column
+	record  []string
+	started bool
+}
+
+// NewCSVEncoder returns an encoder that writes the given columns to `w`, or
+// `DefaultColumns` if there are none.
+func NewCSVEncoder(w io.Writer, columns ...Column) *CSVEncoder {
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+
+	return &CSVEncoder{
+		w:       csv.NewWriter(w),
+		columns: columns,
+		record:  make([]string, len(columns)),
+	}
+}
+
+// Encode implements `Encoder`.
+func (e *CSVEncoder) Encode(trip *uber.Trip) error {
+	if err := e.header(); err != nil {
+		return err
+	}
+
+	for i, column := range e.columns {
+		e.record[i] = column.Value(trip)
+	}
+
+	return e.w.Write(e.record)
+}
+
+// Close implements `Encoder`.
+func (e *CSVEncoder) Close() error {
+	if err := e.header(); err != nil {
+		return err
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// header writes the header if it hasn't been written yet.
+func (e *CSVEncoder) header() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+
+	for i, column := range e.columns {
+		e.record[i] = column.Name
+	}
+
+	return e.w.Write(e.record)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// formatTime formats a time, or an unknown time as "".
+func formatTime(t uber.Timestamp) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+func startCity(t *uber.Trip) string {
+	if t.StartCity == nil {
+		return ""
+	}
+
+	return t.StartCity.DisplayName
+}
+
+func start(t *uber.Trip) *uber.Location { return t.StartLocation }
+func end(t *uber.Trip) *uber.Location   { return t.EndLocation }
+
+func address(l *uber.Location) string   { return l.Address }
+func latitude(l *uber.Location) string  { return formatFloat(l.Latitude) }
+func longitude(l *uber.Location) string { return formatFloat(l.Longitude) }
+
+// locationField formats a field of one of the locations of a trip.
+func locationField(
+	location func(*uber.Trip) *uber.Location, field func(*uber.Location) string,
+) func(*uber.Trip) string {
+	return func(t *uber.Trip) string {
+		l := location(t)
+		if l == nil {
+			return ""
+		}
+
+		return field(l)
+	}
+}
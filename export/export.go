@@ -0,0 +1,185 @@
+// Package export streams a user's Uber trip history to CSV, JSON Lines or GeoJSON.
+//
+// The history is fetched a page at a time by a bounded pool of workers, and the trips
+// are written in the order of the history, as soon as their page comes in:
+//
+//	client := uber.NewClient(SERVER_TOKEN)
+//	// authorize the client
+//
+//	err := export.Export(ctx, client, export.NewCSVEncoder(w), export.WithWorkers(8))
+package export
+
+import (
+	"context"
+	"sync"
+
+	uber "github.com/r-medina/go-uber"
+)
+
+// DefaultWorkers is how many pages of history `Export` fetches at once, unless told
+// otherwise with `WithWorkers`.
+const DefaultWorkers = 4
+
+// HistorySource is where `Export` gets the history from. `*uber.Client` implements it.
+type HistorySource interface {
+	GetUserActivityVersion(
+		ctx context.Context, version uber.HistoryVersion, offset, limit int,
+	) (*uber.UserActivity, error)
+}
+
+var _ HistorySource = (*uber.Client)(nil)
+
+// Encoder writes trips in some format. See `NewCSVEncoder`, `NewJSONLinesEncoder` and
+// `NewGeoJSONEncoder`.
+type Encoder interface {
+	// Encode writes a single trip.
+	Encode(trip *uber.Trip) error
+
+	// Close finishes the output, eg: with the closing brackets of a JSON document, and
+	// flushes it. It doesn't close the underlying writer.
+	Close() error
+}
+
+// Option configures `Export`.
+type Option func(*config)
+
+type config struct {
+	workers int
+	version uber.HistoryVersion
+}
+
+// WithWorkers sets how many pages of history are fetched at once.
+func WithWorkers(n int) Option {
+	return func(c *config) {
+		c.workers = n
+	}
+}
+
+// WithVersion exports the history from the given version of the history endpoint,
+// rather than `uber.HistoryV12`.
+func WithVersion(version uber.HistoryVersion) Option {
+	return func(c *config) {
+		c.version = version
+	}
+}
+
+// page is the result of fetching a page of history.
+type page struct {
+	trips []*uber.Trip
+	err   error
+}
+
+// Export writes every trip in the history of the user `src` is authorized for to `enc`,
+// and closes `enc`. It stops at the first error, having written the trips before it.
+func Export(ctx context.Context, src HistorySource, enc Encoder, opts ...Option) error {
+	err := export(ctx, src, enc, opts)
+	if cerr := enc.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func export(ctx context.Context, src HistorySource, enc Encoder, opts []Option) error {
+	c := &config{workers: DefaultWorkers, version: uber.HistoryV12}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.workers < 1 {
+		c.workers = 1
+	}
+
+	// the first page says how many pages there are
+	first, err := src.GetUserActivityVersion(ctx, c.version, 0, uber.MaxHistoryLimit)
+	if err != nil {
+		return err
+	}
+	if err := encode(enc, first.History); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	// Pages are handed to the writer in order, each with a channel its trips will come
+	// in on. At most `c.workers` pages are being fetched at once, and the buffer bounds
+	// how many fetched pages can wait to be written.
+	pages := make(chan chan page, c.workers)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(pages)
+
+		sem := make(chan struct{}, c.workers)
+		for offset := uber.MaxHistoryLimit; offset < first.Count; offset += uber.MaxHistoryLimit {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			result := make(chan page, 1)
+			wg.Add(1)
+			go func(offset int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				activity, err := src.GetUserActivityVersion(
+					ctx, c.version, offset, uber.MaxHistoryLimit,
+				)
+				if err != nil {
+					result <- page{err: err}
+					return
+				}
+				result <- page{trips: activity.History}
+			}(offset)
+
+			select {
+			case pages <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	written := 1
+	for result := range pages {
+		p := <-result
+		if p.err != nil {
+			return p.err
+		}
+		if err := encode(enc, p.trips); err != nil {
+			return err
+		}
+		written++
+	}
+
+	// the pages stop coming early if `ctx` is done, but once all of them are written
+	// the export is finished whatever happens to `ctx`
+	if written < pageCount(first.Count) {
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+// pageCount is the number of pages of a history of `count` trips, at least one.
+func pageCount(count int) int {
+	if count <= uber.MaxHistoryLimit {
+		return 1
+	}
+
+	return (count + uber.MaxHistoryLimit - 1) / uber.MaxHistoryLimit
+}
+
+func encode(enc Encoder, trips []*uber.Trip) error {
+	for _, trip := range trips {
+		if err := enc.Encode(trip); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
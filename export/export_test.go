@@ -0,0 +1,255 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	uber "github.com/r-medina/go-uber"
+)
+
+// testSource is a history of `count` trips, whose `Uuid`s are their positions. The
+// page at offset `failAt`, if any, gets an error. Later pages are slower, so they come
+// back out of order.
+type testSource struct {
+	count  int
+	failAt int
+
+	mu           sync.Mutex
+	active, peak int
+	offsets      []int
+}
+
+var errTest = errors.New("test error")
+
+func (s *testSource) GetUserActivityVersion(
+	ctx context.Context, version uber.HistoryVersion, offset, limit int,
+) (*uber.UserActivity, error) {
+	s.mu.Lock()
+	s.offsets = append(s.offsets, offset)
+	if s.active++; s.active > s.peak {
+		s.peak = s.active
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.active--
+		s.mu.Unlock()
+	}()
+
+	select {
+	case <-time.After(time.Duration(offset/limit%3) * time.Millisecond):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if s.failAt > 0 && offset == s.failAt {
+		return nil, errTest
+	}
+
+	activity := &uber.UserActivity{Version: version, Offset: offset, Limit: limit, Count: s.count}
+	for i := offset; i < s.count && i < offset+limit; i++ {
+		activity.History = append(activity.History, &uber.Trip{
+			Uuid:          strconv.Itoa(i),
+			ProductID:     "a1111c8c",
+			Status:        "completed",
+			Distance:      1.5,
+			StartedAt:     uber.Timestamp{Time: time.Unix(1428876188, 0)},
+			EndedAt:       uber.Timestamp{Time: time.Unix(1428876927, 0)},
+			StartLocation: &uber.Location{Latitude: 37.7749295, Longitude: -122.4194155},
+			EndLocation:   &uber.Location{Latitude: 37.7860099, Longitude: -122.4025387},
+		})
+	}
+
+	return activity, nil
+}
+
+func TestExportOrder(t *testing.T) {
+	t.Parallel()
+
+	src := &testSource{count: 1234}
+	buf := new(bytes.Buffer)
+
+	err := Export(context.Background(), src, NewCSVEncoder(buf, ColumnUUID), WithWorkers(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1235 || records[0][0] != "uuid" {
+		t.Fatalf("expected a header and 1234 trips, got %d records", len(records))
+	}
+	for i, record := range records[1:] {
+		if record[0] != strconv.Itoa(i) {
+			t.Fatalf("expected trip %d, got %s", i, record[0])
+		}
+	}
+
+	if len(src.offsets) != 25 {
+		t.Fatalf("expected 25 pages, got %d", len(src.offsets))
+	}
+	if src.peak > 3 {
+		t.Fatalf("expected at most 3 pages to be fetched at once, got %d", src.peak)
+	}
+}
+
+func TestExportError(t *testing.T) {
+	t.Parallel()
+
+	src := &testSource{count: 500, failAt: 200}
+	buf := new(bytes.Buffer)
+
+	err := Export(context.Background(), src, NewJSONLinesEncoder(buf))
+	if err != errTest {
+		t.Fatalf("expected %v, got %v", errTest, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 200 {
+		t.Fatalf("expected the 200 trips before the error, got %d", len(lines))
+	}
+
+	trip := new(uber.Trip)
+	if err := json.Unmarshal([]byte(lines[199]), trip); err != nil {
+		t.Fatal(err)
+	}
+	if trip.Uuid != "199" {
+		t.Fatalf("expected trip 199, got %s", trip.Uuid)
+	}
+}
+
+func TestExportContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Export(ctx, &testSource{count: 500}, NewJSONLinesEncoder(new(bytes.Buffer)))
+	if err != context.Canceled {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+// cancelingEncoder cancels the export once it has been given the trip `last`.
+type cancelingEncoder struct {
+	Encoder
+	last   string
+	cancel context.CancelFunc
+}
+
+func (e *cancelingEncoder) Encode(trip *uber.Trip) error {
+	if trip.Uuid == e.last {
+		e.cancel()
+	}
+
+	return e.Encoder.Encode(trip)
+}
+
+func TestExportContextAfterLastPage(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// every trip is written, so the export is finished when the context is canceled
+	buf := new(bytes.Buffer)
+	enc := &cancelingEncoder{Encoder: NewJSONLinesEncoder(buf), last: "499", cancel: cancel}
+	if err := Export(ctx, &testSource{count: 500}, enc); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 500 {
+		t.Fatalf("expected 500 trips, got %d", lines)
+	}
+}
+
+func TestCSVEncoder(t *testing.T) {
+	t.Parallel()
+
+	cols, err := ColumnsByName("request_id", "start_time", "start_address", "distance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ColumnsByName("fare"); err == nil {
+		t.Fatal("expected an error for an unknown column")
+	}
+
+	buf := new(bytes.Buffer)
+	enc := NewCSVEncoder(buf, cols...)
+	enc.Encode(&uber.Trip{
+		RequestID:     "37d57a99",
+		StartedAt:     uber.Timestamp{Time: time.Unix(1428876188, 0)},
+		StartLocation: &uber.Location{Address: "706 Mission St, San Francisco, CA"},
+		Distance:      1.64691465,
+	})
+	enc.Encode(&uber.Trip{RequestID: "5152dcc5"})
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `request_id,start_time,start_address,distance
+37d57a99,2015-04-12T22:03:08Z,"706 Mission St, San Francisco, CA",1.64691465
+5152dcc5,,,0
+`
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, buf)
+	}
+}
+
+func TestGeoJSONEncoder(t *testing.T) {
+	t.Parallel()
+
+	buf := new(bytes.Buffer)
+	if err := Export(context.Background(), &testSource{count: 2}, NewGeoJSONEncoder(buf)); err != nil {
+		t.Fatal(err)
+	}
+
+	collection := new(struct {
+		Type     string
+		Features []struct {
+			Type     string
+			Geometry struct {
+				Type        string
+				Coordinates [][2]float64
+			}
+			Properties map[string]interface{}
+		}
+	})
+	if err := json.Unmarshal(buf.Bytes(), collection); err != nil {
+		t.Fatalf("invalid GeoJSON: %v\n%s", err, buf)
+	}
+
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 2 {
+		t.Fatalf("unexpected collection %+v", collection)
+	}
+	f := collection.Features[1]
+	if f.Type != "Feature" || f.Geometry.Type != "LineString" {
+		t.Fatalf("unexpected feature %+v", f)
+	}
+	if fmt.Sprint(f.Geometry.Coordinates) != "[[-122.4194155 37.7749295] [-122.4025387 37.7860099]]" {
+		t.Fatalf("unexpected coordinates %v", f.Geometry.Coordinates)
+	}
+	if f.Properties["uuid"] != "1" || f.Properties["product_id"] != "a1111c8c" ||
+		f.Properties["end_time"] != "2015-04-12T22:15:27Z" {
+		t.Fatalf("unexpected properties %v", f.Properties)
+	}
+
+	// an empty history is still a valid document
+	buf.Reset()
+	if err := Export(context.Background(), &testSource{}, NewGeoJSONEncoder(buf)); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), collection); err != nil {
+		t.Fatalf("invalid GeoJSON: %v\n%s", err, buf)
+	}
+}
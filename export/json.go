@@ -0,0 +1,128 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	uber "github.com/r-medina/go-uber"
+)
+
+// JSONLinesEncoder writes trips as JSON Lines (https://jsonlines.org): each trip is the
+// JSON object the Uber api returned for it, on its own line.
+type JSONLinesEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewJSONLinesEncoder returns an encoder that writes to `w`.
+func NewJSONLinesEncoder(w io.Writer) *JSONLinesEncoder {
+	bw := bufio.NewWriter(w)
+	return &JSONLinesEncoder{w: bw, enc: json.NewEncoder(bw)}
+}
+
+// Encode implements `Encoder`.
+func (e *JSONLinesEncoder) Encode(trip *uber.Trip) error {
+	return e.enc.Encode(trip)
+}
+
+// Close implements `Encoder`.
+func (e *JSONLinesEncoder) Close() error {
+	return e.w.Flush()
+}
+
+// GeoJSONEncoder writes trips as a GeoJSON FeatureCollection (RFC 7946). Each trip is a
+// Feature whose geometry is a LineString from its start to its end location, with its
+// product, status, distance and times as properties. The geometry of a trip without
+// both locations is null.
+type GeoJSONEncoder struct {
+	w       *bufio.Writer
+	started bool
+}
+
+// NewGeoJSONEncoder returns an encoder that writes to `w`.
+func NewGeoJSONEncoder(w io.Writer) *GeoJSONEncoder {
+	return &GeoJSONEncoder{w: bufio.NewWriter(w)}
+}
+
+type feature struct {
+	Type       string      `json:"type"`
+	Geometry   *lineString `json:"geometry"`
+	Properties properties  `json:"properties"`
+}
+
+type lineString struct {
+	Type        string       `json:"type"`
+	Coordinates [][2]float64 `json:"coordinates"`
+}
+
+type properties struct {
+	UUID        string  `json:"uuid,omitempty"`
+	RequestID   string  `json:"request_id,omitempty"`
+	ProductID   string  `json:"product_id"`
+	Status      string  `json:"status"`
+	Distance    float64 `json:"distance"`
+	RequestTime string  `json:"request_time,omitempty"`
+	StartTime   string  `json:"start_time,omitempty"`
+	EndTime     string  `json:"end_time,omitempty"`
+	StartCity   string  `json:"start_city,omitempty"`
+}
+
+// Encode implements `Encoder`.
+func (e *GeoJSONEncoder) Encode(trip *uber.Trip) error {
+	f := feature{
+		Type: "Feature",
+		Properties: properties{
+			UUID:        trip.Uuid,
+			RequestID:   trip.RequestID,
+			ProductID:   trip.ProductID,
+			Status:      string(trip.Status),
+			Distance:    trip.Distance,
+			RequestTime: formatTime(trip.RequestedAt),
+			StartTime:   formatTime(trip.StartedAt),
+			EndTime:     formatTime(trip.EndedAt),
+			StartCity:   startCity(trip),
+		},
+	}
+	if trip.StartLocation != nil && trip.EndLocation != nil {
+		// GeoJSON positions are longitude first
+		f.Geometry = &lineString{
+			Type: "LineString",
+			Coordinates: [][2]float64{
+				{trip.StartLocation.Longitude, trip.StartLocation.Latitude},
+				{trip.EndLocation.Longitude, trip.EndLocation.Latitude},
+			},
+		}
+	}
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if !e.started {
+		sep = `{"type":"FeatureCollection","features":[` + "\n"
+		e.started = true
+	}
+	if _, err := e.w.WriteString(sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+
+	return err
+}
+
+// Close implements `Encoder`.
+func (e *GeoJSONEncoder) Close() error {
+	end := "\n]}\n"
+	if !e.started {
+		end = `{"type":"FeatureCollection","features":[]}` + "\n"
+		e.started = true
+	}
+	if _, err := e.w.WriteString(end); err != nil {
+		return err
+	}
+
+	return e.w.Flush()
+}
@@ -0,0 +1,53 @@
+package uber
+
+import (
+	"context"
+	"iter"
+)
+
+// MaxHistoryLimit is the largest page of trips the history endpoint returns.
+const MaxHistoryLimit = 50
+
+// Trips returns an iterator over every trip in the user's activity, from the given
+// version of the history endpoint. It gets the activity a page of `MaxHistoryLimit` trips
+// at a time, as the iteration goes, until `Count` trips have been yielded.
+//
+// An error, eg: from the api or because `ctx` is done, is yielded with a nil trip and
+// ends the iteration; the trips before it have already been yielded.
+//
+// eg:
+//
+//	for trip, err := range client.Trips(ctx, uber.HistoryV12) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(trip.RequestID)
+//	}
+func (c *Client) Trips(ctx context.Context, version HistoryVersion) iter.Seq2[*Trip, error] {
+	return func(yield func(*Trip, error) bool) {
+		for offset := 0; ; {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			userActivity, err := c.GetUserActivityVersion(ctx, version, offset, MaxHistoryLimit)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			for _, trip := range userActivity.History {
+				if !yield(trip, nil) {
+					return
+				}
+			}
+
+			// an empty page means the history is shorter than `Count` claimed
+			offset += len(userActivity.History)
+			if len(userActivity.History) == 0 || offset >= userActivity.Count {
+				return
+			}
+		}
+	}
+}
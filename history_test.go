@@ -0,0 +1,153 @@
+package uber
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// historyServer serves a history of `count` trips, whose `Uuid`s are their positions.
+// The page at offset `failAt`, if any, gets an error.
+type historyServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	limits []int
+}
+
+func newHistoryServer(count, failAt int) *historyServer {
+	s := new(historyServer)
+	s.Server = httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			offset, _ := strconv.Atoi(req.URL.Query().Get("offset"))
+			limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+
+			s.mu.Lock()
+			s.limits = append(s.limits, limit)
+			s.mu.Unlock()
+
+			if failAt > 0 && offset == failAt {
+				rw.WriteHeader(http.StatusInternalServerError)
+				rw.Write([]byte(`{"message": "oops", "code": "internal_server_error"}`))
+				return
+			}
+
+			activity := UserActivity{Offset: offset, Limit: limit, Count: count}
+			for i := offset; i < count && i < offset+limit; i++ {
+				activity.History = append(activity.History, &Trip{Uuid: strconv.Itoa(i)})
+			}
+			json.NewEncoder(rw).Encode(activity)
+		},
+	))
+
+	return s
+}
+
+func TestTrips(t *testing.T) {
+	t.Parallel()
+
+	server := newHistoryServer(120, 0)
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
+
+	n := 0
+	for trip, err := range client.Trips(context.Background(), HistoryV1) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trip.Uuid != strconv.Itoa(n) {
+			t.Fatalf("expected trip %d, got %s", n, trip.Uuid)
+		}
+		n++
+	}
+
+	if n != 120 {
+		t.Fatalf("expected 120 trips, got %d", n)
+	}
+	if fmt.Sprint(server.limits) != "[50 50 50]" {
+		t.Fatalf("expected 3 pages of %d, got %v", MaxHistoryLimit, server.limits)
+	}
+}
+
+func TestTripsBreak(t *testing.T) {
+	t.Parallel()
+
+	server := newHistoryServer(120, 0)
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
+
+	n := 0
+	for range client.Trips(context.Background(), HistoryV1) {
+		if n++; n == 10 {
+			break
+		}
+	}
+
+	if len(server.limits) != 1 {
+		t.Fatalf("expected 1 page to be fetched, got %d", len(server.limits))
+	}
+}
+
+func TestTripsError(t *testing.T) {
+	t.Parallel()
+
+	server := newHistoryServer(120, 50)
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
+
+	n := 0
+	var lastErr error
+	for trip, err := range client.Trips(context.Background(), HistoryV1) {
+		if err != nil {
+			if trip != nil {
+				t.Fatal("expected no trip with the error")
+			}
+			lastErr = err
+			continue
+		}
+		n++
+	}
+
+	if n != 50 {
+		t.Fatalf("expected the 50 trips before the error, got %d", n)
+	}
+	if _, ok := lastErr.(uberError); !ok {
+		t.Fatalf("expected an uberError, got %v", lastErr)
+	}
+}
+
+func TestTripsContext(t *testing.T) {
+	t.Parallel()
+
+	server := newHistoryServer(120, 0)
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n := 0
+	var lastErr error
+	for _, err := range client.Trips(ctx, HistoryV1) {
+		if err != nil {
+			lastErr = err
+			break
+		}
+		if n++; n == 50 {
+			cancel()
+		}
+	}
+
+	if n != 50 || lastErr != context.Canceled {
+		t.Fatalf("expected to stop after 50 trips with %v, got %d and %v",
+			context.Canceled, n, lastErr)
+	}
+	if len(server.limits) != 1 {
+		t.Fatalf("expected 1 page to be fetched, got %d", len(server.limits))
+	}
+}
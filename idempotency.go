@@ -0,0 +1,176 @@
+package uber
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrKeyNotFound is returned by an `IdempotencyStore` when it holds nothing for a key.
+var ErrKeyNotFound = errors.New("uber: no ride request stored for idempotency key")
+
+// IdempotencyStore remembers which ride request was made for each idempotency key (see
+// `WithIdempotencyKey`), so that retrying a booking doesn't dispatch a second car.
+//
+// Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Load returns the ID of the ride request saved for `key`, or `ErrKeyNotFound`. The
+	// ID is empty if a request was being made for `key` when it was last heard of, and
+	// it isn't known whether it went through.
+	Load(key string) (requestID string, err error)
+
+	// Save stores the ID of the ride request for `key`, replacing any previous one.
+	Save(key, requestID string) error
+
+	// Delete forgets `key`. Deleting a key that doesn't exist is not an error.
+	Delete(key string) error
+}
+
+// idempotencyKey is the type of the context key under which `WithIdempotencyKey`
+// stores the idempotency key.
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a copy of `ctx` that makes `RequestRide` idempotent: every
+// call with the same `key` books at most one ride, and returns it. Use a new key for
+// every ride, eg: a random UUID, and the same one when retrying a booking that failed or
+// timed out.
+//
+// The rides made for each key are kept in the client's `IdempotencyStore` (see
+// `WithIdempotencyStore`). Calls with the same key must not be made concurrently.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// bookRide makes a ride request, idempotently if `ctx` carries an idempotency key.
+//
+// For a key that is new, the key is saved without a ride request ID before booking, and
+// with it once the ride is booked. A retry then finds either:
+//
+//   - the ID, and the ride is retrieved rather than booked again;
+//   - no ID, because the earlier attempt failed without it being known whether the api
+//     booked the ride, eg: it timed out. If the user is on a ride, it is taken to be
+//     that one; otherwise the ride is booked.
+//
+// An attempt that the api turned down is forgotten, so that it can be retried.
+func (c *Client) bookRide(ctx context.Context, payload requestReq) (*Request, error) {
+	key, ok := ctx.Value(idempotencyKey{}).(string)
+	if !ok || key == "" {
+		return c.postRequest(ctx, payload)
+	}
+
+	requestID, err := c.idempotencyStore.Load(key)
+	switch {
+	case err == ErrKeyNotFound:
+	case err != nil:
+		return nil, err
+	case requestID != "":
+		return c.GetRequestContext(ctx, requestID)
+	default:
+		request, err := c.GetCurrentRequest(ctx)
+		if err == nil {
+			return request, c.idempotencyStore.Save(key, request.RequestID)
+		} else if err != ErrNoCurrentRequest {
+			return nil, err
+		}
+	}
+
+	if err := c.idempotencyStore.Save(key, ""); err != nil {
+		return nil, err
+	}
+
+	request, err := c.postRequest(ctx, payload)
+	if err != nil {
+		// only a 4xx means for sure that no ride was booked
+		if code := statusCode(err); code >= 400 && code < 500 {
+			c.idempotencyStore.Delete(key)
+		}
+		return nil, err
+	}
+
+	return request, c.idempotencyStore.Save(key, request.RequestID)
+}
+
+// postRequest books a ride. A `SurgeRequiredError` it returns carries `payload`, so that
+// the ride can be booked again once the surge pricing is confirmed.
+func (c *Client) postRequest(ctx context.Context, payload requestReq) (*Request, error) {
+	// only `FareVersion` books rides at an upfront fare
+	host := c.host(RequestEndpoint)
+	if payload.fareID != "" {
+		host = c.versionedHost(RequestEndpoint, FareVersion)
+	}
+
+	request := new(requestResp)
+	err := c.httpReqDoHost(ctx, host, "POST", RequestEndpoint, payload, true, request)
+	if err != nil {
+		var surgeErr *SurgeRequiredError
+		if errors.As(err, &surgeErr) {
+			surgeErr.request = &payload
+		}
+		return nil, err
+	}
+
+	return &request.Request, nil
+}
+
+// IdempotencyKeyTTL is how long a `MemoryIdempotencyStore` remembers a key after it was
+// last saved. Retrying a booking with the key after that may book another ride.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// MemoryIdempotencyStore is an `IdempotencyStore` that keeps keys in memory, for
+// `IdempotencyKeyTTL`. It is the store of new clients, which makes retries within a
+// process idempotent.
+type MemoryIdempotencyStore struct {
+	mu   sync.RWMutex
+	keys map[string]idempotencyEntry
+	now  func() time.Time
+}
+
+// idempotencyEntry is the ride request saved for a key, and when it was saved.
+type idempotencyEntry struct {
+	requestID string
+	saved     time.Time
+}
+
+// NewMemoryIdempotencyStore returns an empty `MemoryIdempotencyStore`.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{keys: make(map[string]idempotencyEntry), now: time.Now}
+}
+
+// Load implements `IdempotencyStore`.
+func (s *MemoryIdempotencyStore) Load(key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.keys[key]
+	if !ok || s.now().Sub(entry.saved) > IdempotencyKeyTTL {
+		return "", ErrKeyNotFound
+	}
+
+	return entry.requestID, nil
+}
+
+// Save implements `IdempotencyStore`. It also forgets the keys that have expired.
+func (s *MemoryIdempotencyStore) Save(key, requestID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for k, entry := range s.keys {
+		if now.Sub(entry.saved) > IdempotencyKeyTTL {
+			delete(s.keys, k)
+		}
+	}
+
+	s.keys[key] = idempotencyEntry{requestID: requestID, saved: now}
+	return nil
+}
+
+// Delete implements `IdempotencyStore`.
+func (s *MemoryIdempotencyStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, key)
+	return nil
+}
@@ -0,0 +1,222 @@
+package uber
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// How a `rideServer` answers a booking.
+const (
+	bookOK         = iota
+	bookDropBefore // the connection drops before the ride is booked
+	bookDropAfter  // the ride is booked, but the connection drops before the response
+	bookReject     // the api turns the booking down
+)
+
+// rideServer is an api that books rides, and can fail while doing it.
+type rideServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	bookings []int // how to answer the next bookings
+	rides    []string
+	requests []string
+}
+
+func newRideServer(bookings ...int) *rideServer {
+	s := &rideServer{bookings: bookings}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+func (s *rideServer) handle(rw http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, req.Method+" "+req.URL.Path)
+
+	switch {
+	case req.Method == "POST" && req.URL.Path == "/requests":
+		mode := bookOK
+		if len(s.bookings) > 0 {
+			mode, s.bookings = s.bookings[0], s.bookings[1:]
+		}
+
+		if mode == bookReject {
+			rw.WriteHeader(http.StatusConflict)
+			rw.Write([]byte(`{"message": "surge", "code": "surge"}`))
+			return
+		}
+		if mode != bookDropBefore {
+			s.rides = append(s.rides, fmt.Sprintf("ride-%d", len(s.rides)+1))
+		}
+		if mode != bookOK {
+			conn, _, _ := rw.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+
+		fmt.Fprintf(rw, `{"request_id": %q, "status": "processing"}`, s.rides[len(s.rides)-1])
+	case req.URL.Path == "/requests/current":
+		if len(s.rides) == 0 {
+			rw.WriteHeader(http.StatusNotFound)
+			rw.Write([]byte(`{"message": "no current trip", "code": "no_current_trip"}`))
+			return
+		}
+
+		fmt.Fprintf(rw, `{"request_id": %q, "status": "accepted"}`, s.rides[len(s.rides)-1])
+	case strings.HasPrefix(req.URL.Path, "/requests/"):
+		fmt.Fprintf(rw, `{"request_id": %q, "status": "accepted"}`,
+			strings.TrimPrefix(req.URL.Path, "/requests/"))
+	default:
+		http.NotFound(rw, req)
+	}
+}
+
+func (s *rideServer) book(client *Client, ctx context.Context) (*Request, error) {
+	return client.PostRequestContext(ctx, "a1111c8c", 37.775, -122.417, 37.786, -122.402, "")
+}
+
+func TestIdempotentBooking(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name     string
+		bookings []int
+		requests []string
+	}{
+		{
+			name:     "retry after success",
+			bookings: []int{bookOK},
+			requests: []string{"POST /requests", "GET /requests/ride-1"},
+		},
+		{
+			name:     "booked but the response was lost",
+			bookings: []int{bookDropAfter},
+			requests: []string{"POST /requests", "GET /requests/current"},
+		},
+		{
+			name:     "lost before booking",
+			bookings: []int{bookDropBefore, bookOK},
+			requests: []string{"POST /requests", "GET /requests/current", "POST /requests"},
+		},
+		{
+			name:     "turned down",
+			bookings: []int{bookReject, bookOK},
+			requests: []string{"POST /requests", "POST /requests"},
+		},
+	} {
+		server := newRideServer(test.bookings...)
+		store := NewMemoryIdempotencyStore()
+		client := NewClient(testServerToken, WithAPIHost(server.URL), WithIdempotencyStore(store))
+		ctx := WithIdempotencyKey(context.Background(), "booking-1")
+
+		// the first attempt may fail, but the retry gets the one ride
+		server.book(client, ctx)
+		request, err := server.book(client, ctx)
+		server.Close()
+
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(server.rides) != 1 || request.RequestID != server.rides[0] {
+			t.Fatalf("%s: expected a single ride, got %v and %+v", test.name, server.rides, request)
+		}
+		if fmt.Sprint(server.requests) != fmt.Sprint(test.requests) {
+			t.Fatalf("%s: expected requests %v, got %v", test.name, test.requests, server.requests)
+		}
+		if requestID, err := store.Load("booking-1"); err != nil || requestID != server.rides[0] {
+			t.Fatalf("%s: expected the ride to be stored, got %q, %v", test.name, requestID, err)
+		}
+	}
+}
+
+func TestIdempotentBookingFailures(t *testing.T) {
+	t.Parallel()
+
+	server := newRideServer(bookDropBefore, bookOK)
+	defer server.Close()
+	store := NewMemoryIdempotencyStore()
+	client := NewClient(testServerToken, WithAPIHost(server.URL), WithIdempotencyStore(store))
+	ctx := WithIdempotencyKey(context.Background(), "booking-1")
+
+	// a booking whose outcome isn't known stays pending
+	if _, err := server.book(client, ctx); err == nil {
+		t.Fatal("expected the dropped connection to fail the booking")
+	}
+	if requestID, err := store.Load("booking-1"); err != nil || requestID != "" {
+		t.Fatalf("expected the key to be pending, got %q, %v", requestID, err)
+	}
+
+	// other keys, and bookings without one, aren't affected
+	if _, err := server.book(client, WithIdempotencyKey(context.Background(), "booking-2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.book(client, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.rides) != 2 {
+		t.Fatalf("expected 2 rides, got %v", server.rides)
+	}
+
+	// a turned down booking is forgotten
+	server.bookings = []int{bookReject}
+	if _, err := server.book(client, WithIdempotencyKey(context.Background(), "booking-3")); err == nil {
+		t.Fatal("expected the booking to be turned down")
+	}
+	if _, err := store.Load("booking-3"); err != ErrKeyNotFound {
+		t.Fatalf("expected the key to be forgotten, got %v", err)
+	}
+}
+
+func TestMemoryIdempotencyStoreTTL(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	store := NewMemoryIdempotencyStore()
+	store.now = func() time.Time { return now }
+
+	store.Save("booking-1", "ride-1")
+	now = now.Add(IdempotencyKeyTTL)
+	if requestID, err := store.Load("booking-1"); err != nil || requestID != "ride-1" {
+		t.Fatalf("expected ride-1 until the key expires, got %q, %v", requestID, err)
+	}
+
+	// expired keys can't be loaded, and are dropped on the next save
+	now = now.Add(time.Second)
+	if _, err := store.Load("booking-1"); err != ErrKeyNotFound {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+	store.Save("booking-2", "ride-2")
+	if len(store.keys) != 1 {
+		t.Fatalf("expected only booking-2 to be kept, got %v", store.keys)
+	}
+}
+
+func TestGetCurrentRequest(t *testing.T) {
+	t.Parallel()
+
+	server := newRideServer()
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
+
+	if _, err := client.GetCurrentRequest(context.Background()); err != ErrNoCurrentRequest {
+		t.Fatalf("expected ErrNoCurrentRequest, got %v", err)
+	}
+
+	server.rides = []string{"ride-1"}
+	request, err := client.GetCurrentRequest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.RequestID != "ride-1" || request.Status != StatusAccepted {
+		t.Fatalf("unexpected request %+v", request)
+	}
+}
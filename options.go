@@ -0,0 +1,97 @@
+package uber
+
+import (
+	"net/http"
+	"time"
+)
+
+// ClientOption configures a `Client` (see `NewClient`).
+type ClientOption func(*Client)
+
+// WithAPIHost makes the client send api requests to `url` (eg: "https://api.uber.com/v1")
+// rather than `UberAPIHost`.
+func WithAPIHost(url string) ClientOption {
+	return func(c *Client) {
+		c.apiHost = url
+	}
+}
+
+// WithAuthHost makes the client do the OAuth flow against `url` rather than `AuthHost`.
+func WithAuthHost(url string) ClientOption {
+	return func(c *Client) {
+		c.authHost = url
+	}
+}
+
+// WithHTTPClient makes the client send its requests with `hc` rather than a zero
+// `http.Client`.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithUserAgent sets the User-Agent header of the client's requests.
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithTimeout limits how long each of the client's requests may take. It applies to the
+// client's own copy of the `http.Client`, so it doesn't affect one given to
+// `WithHTTPClient`.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithSandbox puts the client in sandbox mode: the ride request endpoints are sent to
+// Uber's sandbox, "https://sandbox-api.uber.com/v1", where no real car is ever
+// dispatched.
+// https://developer.uber.com/docs/riders/guides/sandbox
+func WithSandbox() ClientOption {
+	return func(c *Client) {
+		c.sandbox = true
+	}
+}
+
+// WithBackoff sets how long `WaitForStatus` waits between polls, rather than
+// `DefaultBackoff`. An `Initial` wait that isn't positive is taken to be that of
+// `DefaultBackoff`.
+func WithBackoff(b Backoff) ClientOption {
+	return func(c *Client) {
+		if b.Initial <= 0 {
+			b.Initial = DefaultBackoff.Initial
+		}
+		c.backoff = b
+	}
+}
+
+// WithPollInterval sets how often `WatchRequest` polls, rather than
+// `DefaultPollInterval`. An interval that isn't positive leaves the default.
+func WithPollInterval(d time.Duration) ClientOption {
+	return func(c *Client) {
+		if d > 0 {
+			c.pollInterval = d
+		}
+	}
+}
+
+// WithIdempotencyStore makes the client keep the rides booked for each idempotency key
+// (see `WithIdempotencyKey`) in `store`, eg: so that retries after a restart are
+// idempotent too, rather than in memory.
+func WithIdempotencyStore(store IdempotencyStore) ClientOption {
+	return func(c *Client) {
+		c.idempotencyStore = store
+	}
+}
+
+// WithSandboxHost is like `WithSandbox` but sends the ride request endpoints to `url`.
+func WithSandboxHost(url string) ClientOption {
+	return func(c *Client) {
+		c.sandbox = true
+		c.sandboxHost = url
+	}
+}
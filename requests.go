@@ -8,25 +8,58 @@ package uber
 type authReq struct {
 	// cannot be pointer because of reflection in `generateRequestURLHelper`
 	auth
-	responseType string `query:"response_type,required"`
-	scope        string `query:"scope"`
-	state        string `query:"state"`
+	responseType        string `query:"response_type,required"`
+	scope               string `query:"scope"`
+	state               string `query:"state"`
+	codeChallenge       string `query:"code_challenge"`
+	codeChallengeMethod string `query:"code_challenge_method"`
 }
 
+// the client secret is optional because public clients use PKCE instead
 type accReq struct {
 	auth
-	clientSecret string `query:"client_secret,required"`
+	clientSecret string `query:"client_secret"`
 	grantType    string `query:"grant_type,required"`
 	code         string `query:"code,required"`
+	codeVerifier string `query:"code_verifier"`
 }
 
+type refreshReq struct {
+	auth
+	clientSecret string `query:"client_secret"`
+	grantType    string `query:"grant_type,required"`
+	refreshToken string `query:"refresh_token,required"`
+}
+
+// the coordinates are pointers so that they are left out for places given otherwise,
+// and for the end of open-ended rides
 type requestReq struct {
-	productID           string  `query:"product_id,required"`
-	startLatitude       float64 `query:"start_latitude,required"`
-	startLongitude      float64 `query:"start_longitude,required"`
-	endLatitude         float64 `query:"end_latitude,required"`
-	endLongitude        float64 `query:"end_longitude,required"`
-	surgeConfirmationID string  `query:"surge_confirmation_id"`
+	productID           string   `query:"product_id,required"`
+	startLatitude       *float64 `query:"start_latitude"`
+	startLongitude      *float64 `query:"start_longitude"`
+	startPlaceID        string   `query:"start_place_id"`
+	startAddress        string   `query:"start_address"`
+	endLatitude         *float64 `query:"end_latitude"`
+	endLongitude        *float64 `query:"end_longitude"`
+	endPlaceID          string   `query:"end_place_id"`
+	endAddress          string   `query:"end_address"`
+	seatCount           *int     `query:"seat_count"`
+	paymentMethodID     string   `query:"payment_method_id"`
+	surgeConfirmationID string   `query:"surge_confirmation_id"`
+	fareID              string   `query:"fare_id"`
+}
+
+type requestEstimateReq struct {
+	productID      string   `query:"product_id"`
+	startLatitude  *float64 `query:"start_latitude"`
+	startLongitude *float64 `query:"start_longitude"`
+	startPlaceID   string   `query:"start_place_id"`
+	startAddress   string   `query:"start_address"`
+	endLatitude    *float64 `query:"end_latitude"`
+	endLongitude   *float64 `query:"end_longitude"`
+	endPlaceID     string   `query:"end_place_id"`
+	endAddress     string   `query:"end_address"`
+	seatCount      *int     `query:"seat_count"`
 }
 
 type requestResp struct {
@@ -79,3 +112,12 @@ type historyReq struct {
 	offset int `query:"offset,required"`
 	limit  int `query:"limit,required"`
 }
+
+type sandboxRequestReq struct {
+	status string `query:"status,required"`
+}
+
+type sandboxProductReq struct {
+	surgeMultiplier  float64 `query:"surge_multiplier"`
+	driversAvailable bool    `query:"drivers_available"`
+}
@@ -0,0 +1,204 @@
+package uber
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The places a user can save, for `Place.PlaceID`.
+const (
+	PlaceHome = "home"
+	PlaceWork = "work"
+)
+
+// MaxSeatCount is the most seats a shared ride, eg: uberPOOL, can be booked for.
+const MaxSeatCount = 2
+
+// ErrInvalidRideSpec is wrapped by the errors of `RideSpec.Validate`.
+var ErrInvalidRideSpec = errors.New("uber: invalid ride")
+
+// RideSpec describes a ride, to get an estimate for (see `EstimateRequest`) or to book
+// (see `RequestRide`). It is checked with `Validate` before any call to the api, eg:
+//
+//	spec := uber.RideSpec{
+//		ProductID: "a1111c8c-c720-46c3-8534-2fcdd730040d",
+//		Start:     &uber.Place{PlaceID: uber.PlaceHome},
+//		End:       uber.At(37.786, -122.402),
+//	}
+type RideSpec struct {
+	// The product to ride in, eg: UberX. An estimate without one is for the default
+	// product at the start location.
+	// eg: "a1111c8c-c720-46c3-8534-2fcdd730040d"
+	ProductID string
+
+	// Where the ride starts
+	Start *Place
+
+	// Where the ride ends, nil for an open-ended ride whose destination the user gives
+	// the driver
+	End *Place
+
+	// How many seats to book on a shared ride, eg: uberPOOL, up to `MaxSeatCount`; 0
+	// for the default
+	SeatCount int
+
+	// The payment method to charge, empty for the user's default
+	// eg: "5f384f7d-8323-4207-a297-51c571234a8c"
+	PaymentMethodID string
+
+	// The `Fare.FareID` of an estimate, to book the ride at the estimated fare
+	FareID string
+
+	// The ID Uber gives once the user accepted surge pricing (see `SurgeRequiredError`)
+	SurgeConfirmationID string
+}
+
+// Place is where a ride starts or ends. It is given by its coordinates unless it has a
+// `PlaceID` or an `Address`; only one of the three may be set. Use `At` for the place
+// at (0, 0), which a `Place` literal can't tell from no coordinates.
+type Place struct {
+	// eg: 37.775
+	Latitude float64
+
+	// eg: -122.417
+	Longitude float64
+
+	// A place the user saved
+	// eg: `PlaceHome`
+	PlaceID string
+
+	// eg: "685 Market St, San Francisco, CA 94103"
+	Address string
+
+	// whether the place was given by its coordinates with `At`, whatever they are
+	coordinates bool
+}
+
+// At returns the `Place` at the given coordinates.
+func At(lat, lon float64) *Place {
+	return &Place{Latitude: lat, Longitude: lon, coordinates: true}
+}
+
+// Validate reports whether the ride can be booked, and why not: eg: it has no start, a
+// place is given in two ways or an upfront fare is used for an open-ended ride. The
+// error wraps `ErrInvalidRideSpec`.
+func (s RideSpec) Validate() error {
+	if s.ProductID == "" {
+		return invalidRideSpec("no product")
+	}
+	if err := s.validatePlaces(); err != nil {
+		return err
+	}
+
+	switch {
+	case s.SeatCount < 0 || s.SeatCount > MaxSeatCount:
+		return invalidRideSpec("the seat count must be between 0 and %d", MaxSeatCount)
+	case s.SeatCount > 0 && s.End == nil:
+		return invalidRideSpec("a shared ride needs a destination")
+	case s.FareID != "" && s.End == nil:
+		return invalidRideSpec("an upfront fare needs a destination")
+	case s.FareID != "" && s.SurgeConfirmationID != "":
+		return invalidRideSpec("a ride booked at an upfront fare needs no surge confirmation")
+	}
+
+	return nil
+}
+
+// validatePlaces checks the places of the ride, which is all an estimate needs.
+func (s RideSpec) validatePlaces() error {
+	if s.Start == nil {
+		return invalidRideSpec("no start")
+	}
+	if err := s.Start.validate("start"); err != nil {
+		return err
+	}
+
+	if s.End == nil {
+		return nil
+	}
+
+	return s.End.validate("end")
+}
+
+// validate checks that the place is given in exactly one way, as the `which` of a ride.
+func (p *Place) validate(which string) error {
+	given := 0
+	if p.coordinates || p.Latitude != 0 || p.Longitude != 0 {
+		given++
+	}
+	if p.PlaceID != "" {
+		given++
+	}
+	if p.Address != "" {
+		given++
+	}
+
+	switch {
+	case given == 0:
+		return invalidRideSpec("the %s is empty", which)
+	case given > 1:
+		return invalidRideSpec(
+			"the %s has more than one of coordinates, a place ID and an address", which,
+		)
+	case p.Latitude < -90 || p.Latitude > 90 || p.Longitude < -180 || p.Longitude > 180:
+		return invalidRideSpec("the %s has invalid coordinates", which)
+	}
+
+	return nil
+}
+
+// invalidRideSpec returns an error that wraps `ErrInvalidRideSpec`.
+func invalidRideSpec(format string, a ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidRideSpec, fmt.Sprintf(format, a...))
+}
+
+// fields returns the place as the api takes it: its coordinates are nil if it is given
+// otherwise, and all is empty for no place.
+func (p *Place) fields() (lat, lon *float64, placeID, address string) {
+	if p == nil {
+		return nil, nil, "", ""
+	}
+	if p.PlaceID == "" && p.Address == "" {
+		lat, lon = &p.Latitude, &p.Longitude
+	}
+
+	return lat, lon, p.PlaceID, p.Address
+}
+
+// seatCount returns the seat count as the api takes it, nil for the default.
+func (s RideSpec) seatCount() *int {
+	if s.SeatCount == 0 {
+		return nil
+	}
+
+	return &s.SeatCount
+}
+
+// requestReq returns the payload that books the ride.
+func (s RideSpec) requestReq() requestReq {
+	req := requestReq{
+		productID:           s.ProductID,
+		seatCount:           s.seatCount(),
+		paymentMethodID:     s.PaymentMethodID,
+		surgeConfirmationID: s.SurgeConfirmationID,
+		fareID:              s.FareID,
+	}
+	req.startLatitude, req.startLongitude, req.startPlaceID, req.startAddress =
+		s.Start.fields()
+	req.endLatitude, req.endLongitude, req.endPlaceID, req.endAddress = s.End.fields()
+
+	return req
+}
+
+// requestEstimateReq returns the payload that gets an estimate for the ride.
+func (s RideSpec) requestEstimateReq() requestEstimateReq {
+	req := requestEstimateReq{
+		productID: s.ProductID,
+		seatCount: s.seatCount(),
+	}
+	req.startLatitude, req.startLongitude, req.startPlaceID, req.startAddress =
+		s.Start.fields()
+	req.endLatitude, req.endLongitude, req.endPlaceID, req.endAddress = s.End.fields()
+
+	return req
+}
@@ -0,0 +1,172 @@
+package uber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRideSpecValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name  string
+		spec  RideSpec
+		valid bool
+	}{
+		{
+			name: "coordinates",
+			spec: RideSpec{
+				ProductID: "a1111c8c", Start: At(37.775, -122.417), End: At(37.786, -122.402),
+			},
+			valid: true,
+		},
+		{
+			name: "saved place and address",
+			spec: RideSpec{
+				ProductID: "a1111c8c",
+				Start:     &Place{PlaceID: PlaceHome},
+				End:       &Place{Address: "685 Market St, San Francisco, CA 94103"},
+				SeatCount: 2,
+			},
+			valid: true,
+		},
+		{
+			name:  "open-ended",
+			spec:  RideSpec{ProductID: "a1111c8c", Start: &Place{PlaceID: PlaceWork}},
+			valid: true,
+		},
+		{
+			name:  "null island",
+			spec:  RideSpec{ProductID: "a1111c8c", Start: At(0, 0), End: At(37.786, -122.402)},
+			valid: true,
+		},
+		{
+			name: "no product",
+			spec: RideSpec{Start: At(37.775, -122.417)},
+		},
+		{
+			name: "no start",
+			spec: RideSpec{ProductID: "a1111c8c", End: At(37.786, -122.402)},
+		},
+		{
+			name: "empty start",
+			spec: RideSpec{ProductID: "a1111c8c", Start: &Place{}},
+		},
+		{
+			name: "start given twice",
+			spec: RideSpec{
+				ProductID: "a1111c8c",
+				Start:     &Place{Latitude: 37.775, Longitude: -122.417, PlaceID: PlaceHome},
+			},
+		},
+		{
+			name: "swapped coordinates",
+			spec: RideSpec{ProductID: "a1111c8c", Start: At(-122.417, 37.775)},
+		},
+		{
+			name: "too many seats",
+			spec: RideSpec{
+				ProductID: "a1111c8c", Start: At(37.775, -122.417), End: At(37.786, -122.402),
+				SeatCount: 3,
+			},
+		},
+		{
+			name: "shared and open-ended",
+			spec: RideSpec{ProductID: "a1111c8c", Start: At(37.775, -122.417), SeatCount: 1},
+		},
+		{
+			name: "upfront fare and open-ended",
+			spec: RideSpec{ProductID: "a1111c8c", Start: At(37.775, -122.417), FareID: "d30e732b"},
+		},
+		{
+			name: "upfront fare and surge",
+			spec: RideSpec{
+				ProductID: "a1111c8c", Start: At(37.775, -122.417), End: At(37.786, -122.402),
+				FareID: "d30e732b", SurgeConfirmationID: "7d604f5e",
+			},
+		},
+	} {
+		err := test.spec.Validate()
+		if test.valid && err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidRideSpec) {
+			t.Fatalf("%s: expected ErrInvalidRideSpec, got %v", test.name, err)
+		}
+	}
+}
+
+func TestRequestRide(t *testing.T) {
+	t.Parallel()
+
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			var body map[string]interface{}
+			json.NewDecoder(req.Body).Decode(&body)
+			bodies = append(bodies, body)
+			rw.Write([]byte(`{"request_id": "852b8fdd", "status": "processing"}`))
+		},
+	))
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
+
+	_, err := client.RequestRide(context.Background(), RideSpec{
+		ProductID:       "a1111c8c",
+		Start:           &Place{PlaceID: PlaceHome},
+		PaymentMethodID: "5f384f7d",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.RequestRide(context.Background(), RideSpec{
+		ProductID: "a1111c8c",
+		Start:     At(37.775, -122.417),
+		End:       &Place{Address: "685 Market St"},
+		SeatCount: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the legacy wrapper sends whatever coordinates it is given
+	if _, err := client.PostRequest("a1111c8c", 37.775, -122.417, 0, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// invalid rides don't get to the api
+	_, err = client.RequestRide(context.Background(), RideSpec{ProductID: "a1111c8c"})
+	if !errors.Is(err, ErrInvalidRideSpec) {
+		t.Fatalf("expected ErrInvalidRideSpec, got %v", err)
+	}
+
+	expected := []map[string]interface{}{
+		{
+			"product_id":        "a1111c8c",
+			"start_place_id":    "home",
+			"payment_method_id": "5f384f7d",
+		},
+		{
+			"product_id":      "a1111c8c",
+			"start_latitude":  37.775,
+			"start_longitude": -122.417,
+			"end_address":     "685 Market St",
+			"seat_count":      float64(2),
+		},
+		{
+			"product_id":      "a1111c8c",
+			"start_latitude":  37.775,
+			"start_longitude": -122.417,
+			"end_latitude":    float64(0),
+			"end_longitude":   float64(0),
+		},
+	}
+	if !reflect.DeepEqual(bodies, expected) {
+		t.Fatalf("expected bodies %v, got %v", expected, bodies)
+	}
+}
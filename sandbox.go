@@ -0,0 +1,58 @@
+package uber
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotSandbox is returned by the sandbox methods of a client that isn't in sandbox mode.
+var ErrNotSandbox = errors.New("uber: client is not in sandbox mode")
+
+//
+// sandbox-only methods
+//
+// In sandbox mode (see `WithSandbox`), no driver ever picks up a ride request. These
+// methods stand in for the driver and for the state of the world, so that an app can
+// be taken through every step of a trip and through the situations that are hard to
+// reproduce for real, eg: surge pricing or no drivers being available.
+// https://developer.uber.com/docs/riders/guides/sandbox
+//
+
+// SetSandboxRequestStatus moves the ride request `requestID` to `status`, eg:
+// `StatusAccepted`, then `StatusArriving`, `StatusInProgress` and `StatusCompleted`.
+func (c *Client) SetSandboxRequestStatus(
+	ctx context.Context, requestID string, status RideStatus,
+) error {
+	if !c.sandbox {
+		return ErrNotSandbox
+	}
+	if !status.Known() {
+		return fmt.Errorf("uber: unknown request status %q", status)
+	}
+
+	return c.httpReqDo(
+		ctx, "PUT", fmt.Sprintf("%s/%s", SandboxRequestEndpoint, requestID),
+		sandboxRequestReq{status: string(status)}, true, nil,
+	)
+}
+
+// SetSandboxProduct sets the surge multiplier of the product `productID`, and whether
+// any of its drivers are available. Ride requests for a product with a multiplier
+// greater than 1 need a surge confirmation; when no drivers are available, they end
+// with `StatusNoDrivers`.
+func (c *Client) SetSandboxProduct(
+	ctx context.Context, productID string, surgeMultiplier float64, driversAvailable bool,
+) error {
+	if !c.sandbox {
+		return ErrNotSandbox
+	}
+
+	return c.httpReqDo(
+		ctx, "PUT", fmt.Sprintf("%s/%s", SandboxProductEndpoint, productID),
+		sandboxProductReq{
+			surgeMultiplier:  surgeMultiplier,
+			driversAvailable: driversAvailable,
+		}, true, nil,
+	)
+}
@@ -0,0 +1,132 @@
+package uber
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// recordingServer records the requests made to it and answers them with `body`.
+type recordingServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+	bodies   []string
+}
+
+func newRecordingServer(body string) *recordingServer {
+	s := new(recordingServer)
+	s.Server = httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			b, _ := io.ReadAll(req.Body)
+
+			s.mu.Lock()
+			s.requests = append(s.requests, req.Method+" "+req.URL.Path)
+			s.bodies = append(s.bodies, string(b))
+			s.mu.Unlock()
+
+			if body == "" {
+				rw.WriteHeader(http.StatusNoContent)
+				return
+			}
+			rw.Write([]byte(body))
+		},
+	))
+
+	return s
+}
+
+func TestSandboxRouting(t *testing.T) {
+	t.Parallel()
+
+	api := newRecordingServer(`{"request_id": "852b8fdd", "products": []}`)
+	defer api.Close()
+	sandbox := newRecordingServer(`{"request_id": "852b8fdd", "href": "https://trip.uber.com/abc"}`)
+	defer sandbox.Close()
+
+	client := NewClient(testServerToken, WithAPIHost(api.URL), WithSandboxHost(sandbox.URL))
+
+	if _, err := client.PostRequest("a1111c8c", 37.775, -122.417, 37.786, -122.402, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetRequest("852b8fdd"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetRequestMap("852b8fdd"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DeleteRequest("852b8fdd"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetProducts(37.775, -122.417); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"POST /requests",
+		"GET /requests/852b8fdd",
+		"GET /requests/852b8fdd/map",
+		"DELETE /requests/852b8fdd",
+	}
+	if len(sandbox.requests) != len(expected) {
+		t.Fatalf("expected sandbox requests %v, got %v", expected, sandbox.requests)
+	}
+	for i := range expected {
+		if sandbox.requests[i] != expected[i] {
+			t.Fatalf("expected sandbox requests %v, got %v", expected, sandbox.requests)
+		}
+	}
+	if len(api.requests) != 1 || api.requests[0] != "GET /products" {
+		t.Fatalf("expected only products on the api host, got %v", api.requests)
+	}
+}
+
+func TestSandboxDefaultHost(t *testing.T) {
+	t.Parallel()
+
+	c := NewClient(testServerToken, WithSandbox())
+	if host := c.host(RequestEndpoint); host != "https://sandbox-api.uber.com/"+Version {
+		t.Fatalf("expected ride requests to go to the sandbox, got %s", host)
+	}
+	u := c.host(SandboxRequestEndpoint) + "/" + SandboxRequestEndpoint
+	if u != UberSandboxAPIHost+"/requests" {
+		t.Fatalf("expected the sandbox endpoints under %s, got %s", UberSandboxAPIHost, u)
+	}
+}
+
+func TestSandboxMethods(t *testing.T) {
+	t.Parallel()
+
+	sandbox := newRecordingServer("")
+	defer sandbox.Close()
+
+	client := NewClient(testServerToken, WithSandboxHost(sandbox.URL))
+
+	if err := client.SetSandboxRequestStatus(context.Background(), "852b8fdd", StatusArriving); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetSandboxProduct(context.Background(), "a1111c8c", 2.2, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []struct{ request, body string }{
+		{"PUT /sandbox/requests/852b8fdd", `{"status":"arriving"}`},
+		{"PUT /sandbox/products/a1111c8c", `{"drivers_available":false,"surge_multiplier":2.2}`},
+	} {
+		if sandbox.requests[i] != expected.request || sandbox.bodies[i] != expected.body {
+			t.Fatalf("expected %s %s, got %s %s",
+				expected.request, expected.body, sandbox.requests[i], sandbox.bodies[i])
+		}
+	}
+
+	if err := client.SetSandboxRequestStatus(context.Background(), "852b8fdd", "teleported"); err == nil {
+		t.Fatal("expected an error for an unknown status")
+	}
+	if err := NewClient(testServerToken).SetSandboxProduct(context.Background(), "a1111c8c", 1, true); err != ErrNotSandbox {
+		t.Fatalf("expected ErrNotSandbox, got %v", err)
+	}
+}
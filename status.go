@@ -0,0 +1,98 @@
+package uber
+
+import "fmt"
+
+// RideStatus is the status of a ride `Request`, eg: `StatusAccepted`. It is a string in
+// JSON, so a status the api adds later is kept as it is; `Known` reports whether it is
+// one of the `Status...` constants.
+//
+// A request starts out `StatusProcessing` and moves through the statuses as the table
+// in `CanTransitionTo` describes, until it reaches a terminal status.
+type RideStatus string
+
+// rideStatuses are the known statuses, mapped to the ones a request can move to next.
+var rideStatuses = map[RideStatus][]RideStatus{
+	StatusProcessing:     {StatusAccepted, StatusNoDrivers, StatusRiderCanceled},
+	StatusAccepted:       {StatusArriving, StatusDriverCanceled, StatusRiderCanceled},
+	StatusArriving:       {StatusInProgress, StatusDriverCanceled, StatusRiderCanceled},
+	StatusInProgress:     {StatusCompleted},
+	StatusNoDrivers:      nil,
+	StatusDriverCanceled: nil,
+	StatusRiderCanceled:  nil,
+	StatusCompleted:      nil,
+}
+
+// String implements `fmt.Stringer`.
+func (s RideStatus) String() string {
+	return string(s)
+}
+
+// Known reports whether `s` is one of the `Status...` constants.
+func (s RideStatus) Known() bool {
+	_, ok := rideStatuses[s]
+	return ok
+}
+
+// IsTerminal reports whether a request in status `s` is over, ie: it was completed,
+// canceled or couldn't be fulfilled.
+func (s RideStatus) IsTerminal() bool {
+	next, ok := rideStatuses[s]
+	return ok && len(next) == 0
+}
+
+// IsActive reports whether a request in status `s` is still going, from the moment it
+// is made until the ride ends.
+func (s RideStatus) IsActive() bool {
+	next, ok := rideStatuses[s]
+	return ok && len(next) > 0
+}
+
+// CanTransitionTo reports whether a request in status `s` can later be in status `next`.
+// The legal moves are:
+//
+//	processing  -> accepted, no_drivers_available, rider_canceled
+//	accepted    -> arriving, driver_canceled, rider_canceled
+//	arriving    -> in_progress, driver_canceled, rider_canceled
+//	in_progress -> completed
+//
+// Statuses can be skipped, eg: from processing to in_progress, since a request that is
+// polled may go through several of them between two polls.
+func (s RideStatus) CanTransitionTo(next RideStatus) bool {
+	for _, status := range rideStatuses[s] {
+		if status == next || status.CanTransitionTo(next) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckTransition returns a `*TransitionError` if a request can't go from status `from`
+// to status `to`, eg: because `to` is unknown or comes before `from`. Only `to` is
+// checked if `from` is unknown, eg: empty for the first status of a request.
+func CheckTransition(from, to RideStatus) error {
+	if !to.Known() || (from.Known() && !from.CanTransitionTo(to)) {
+		return &TransitionError{From: from, To: to}
+	}
+
+	return nil
+}
+
+// TransitionError is returned when a request moves to a status it can't be in, eg:
+// when the api returns an unknown status.
+type TransitionError struct {
+	// The status the request was in
+	From RideStatus
+
+	// The status the request moved to
+	To RideStatus
+}
+
+// Error implements the `error` interface for `TransitionError`.
+func (err *TransitionError) Error() string {
+	if !err.To.Known() {
+		return fmt.Sprintf("uber: unknown request status %q", err.To)
+	}
+
+	return fmt.Sprintf("uber: a request can't go from %q to %q", err.From, err.To)
+}
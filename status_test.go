@@ -0,0 +1,90 @@
+package uber
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRideStatus(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		status           RideStatus
+		terminal, active bool
+		known            bool
+	}{
+		{StatusProcessing, false, true, true},
+		{StatusAccepted, false, true, true},
+		{StatusArriving, false, true, true},
+		{StatusInProgress, false, true, true},
+		{StatusCompleted, true, false, true},
+		{StatusNoDrivers, true, false, true},
+		{StatusDriverCanceled, true, false, true},
+		{StatusRiderCanceled, true, false, true},
+		{"teleported", false, false, false},
+	} {
+		if test.status.IsTerminal() != test.terminal || test.status.IsActive() != test.active ||
+			test.status.Known() != test.known {
+			t.Fatalf("unexpected IsTerminal, IsActive or Known for %s", test.status)
+		}
+	}
+
+	request := new(Request)
+	if err := json.Unmarshal([]byte(`{"status": "teleported"}`), request); err != nil {
+		t.Fatal(err)
+	}
+	if request.Status != "teleported" || request.Status.String() != "teleported" {
+		t.Fatalf("expected an unknown status to be kept, got %q", request.Status)
+	}
+
+	data, err := json.Marshal(Request{Status: StatusArriving})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, request); err != nil {
+		t.Fatal(err)
+	}
+	if request.Status != StatusArriving {
+		t.Fatalf("expected %s, got %s", StatusArriving, request.Status)
+	}
+}
+
+func TestCheckTransition(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		from, to RideStatus
+		legal    bool
+	}{
+		{"", StatusProcessing, true},
+		{StatusProcessing, StatusAccepted, true},
+		{StatusAccepted, StatusArriving, true},
+		{StatusArriving, StatusInProgress, true},
+		{StatusInProgress, StatusCompleted, true},
+		{StatusProcessing, StatusNoDrivers, true},
+		{StatusArriving, StatusRiderCanceled, true},
+		// skipped statuses
+		{StatusProcessing, StatusInProgress, true},
+		{StatusAccepted, StatusCompleted, true},
+		// impossible moves
+		{StatusAccepted, StatusProcessing, false},
+		{StatusInProgress, StatusDriverCanceled, false},
+		{StatusCompleted, StatusInProgress, false},
+		{StatusProcessing, StatusProcessing, false},
+		{StatusAccepted, "teleported", false},
+		{"", "teleported", false},
+	} {
+		err := CheckTransition(test.from, test.to)
+		if test.legal != (err == nil) {
+			t.Fatalf("expected %q -> %q to be legal: %v, got %v", test.from, test.to, test.legal, err)
+		}
+		if err == nil {
+			continue
+		}
+
+		terr, ok := err.(*TransitionError)
+		if !ok || terr.From != test.from || terr.To != test.to {
+			t.Fatalf("unexpected error %#v", err)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package uber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// SurgeRequiredError is returned when a ride request is turned down because surge pricing
+// is in effect for the product. The user has to accept the surge pricing at `Href`, and
+// the ride be requested again with the confirmation ID Uber then gives; `ConfirmSurge`
+// does both.
+// https://developer.uber.com/docs/riders/guides/surge
+type SurgeRequiredError struct {
+	// The surge multiplier, if the api says
+	// eg: 1.4
+	Multiplier float64
+
+	// Where the user accepts the surge pricing
+	// eg: "https://api.uber.com/v1/surge-confirmations/7d604f5e"
+	Href string
+
+	// Unique identifier of the surge pricing
+	// eg: "7d604f5e"
+	SurgeConfirmationID string
+
+	// When the surge pricing stops being offered, if the api says
+	// eg: 1429306470
+	ExpiresAt Timestamp
+
+	err uberError
+
+	// the ride request that was turned down, nil if it wasn't made by this package
+	request *requestReq
+}
+
+// surgeResp is the body of the api's answer to a ride request that needs surge pricing
+// to be confirmed.
+type surgeResp struct {
+	Meta struct {
+		SurgeConfirmation struct {
+			Href                string    `json:"href"`
+			SurgeConfirmationID string    `json:"surge_confirmation_id"`
+			Multiplier          float64   `json:"multiplier"`
+			ExpiresAt           Timestamp `json:"expires_at"`
+		} `json:"surge_confirmation"`
+	} `json:"meta"`
+
+	Errors []struct {
+		Code  string `json:"code"`
+		Title string `json:"title"`
+	} `json:"errors"`
+}
+
+// newSurgeRequiredError returns the `SurgeRequiredError` that `body` describes, or nil if
+// it isn't about surge pricing.
+func newSurgeRequiredError(uberErr uberError, body []byte) *SurgeRequiredError {
+	resp := new(surgeResp)
+	if err := json.Unmarshal(body, resp); err != nil {
+		return nil
+	}
+
+	confirmation := resp.Meta.SurgeConfirmation
+	if confirmation.Href == "" && confirmation.SurgeConfirmationID == "" {
+		return nil
+	}
+
+	// the message is either at the top level or, eg: in v1.2, in a list of errors
+	if uberErr.Message == "" && len(resp.Errors) > 0 {
+		uberErr.Message = resp.Errors[0].Title
+		uberErr.Code = resp.Errors[0].Code
+	}
+
+	return &SurgeRequiredError{
+		Multiplier:          confirmation.Multiplier,
+		Href:                confirmation.Href,
+		SurgeConfirmationID: confirmation.SurgeConfirmationID,
+		ExpiresAt:           confirmation.ExpiresAt,
+		err:                 uberErr,
+	}
+}
+
+// Error implements the `error` interface for `SurgeRequiredError`.
+func (err *SurgeRequiredError) Error() string {
+	if err.Multiplier == 0 {
+		return fmt.Sprintf("uber: surge pricing needs to be confirmed at %s", err.Href)
+	}
+
+	return fmt.Sprintf(
+		"uber: surge pricing of %.1fx needs to be confirmed at %s", err.Multiplier, err.Href,
+	)
+}
+
+// Unwrap returns the error the api answered with.
+func (err *SurgeRequiredError) Unwrap() error {
+	return err.err
+}
+
+// ResubmitRequest requests again the ride that was turned down with `surgeErr`, with
+// the surge confirmation ID Uber gave once the user accepted the surge pricing. It is
+// idempotent under the same conditions as `RequestRide`.
+func (c *Client) ResubmitRequest(
+	ctx context.Context, surgeErr *SurgeRequiredError, surgeConfirmationID string,
+) (*Request, error) {
+	if surgeErr.request == nil {
+		return nil, errors.New("uber: the ride request that needs surge confirmation is unknown")
+	}
+	if err := c.requireScope(ScopeRequest); err != nil {
+		return nil, err
+	}
+
+	payload := *surgeErr.request
+	payload.surgeConfirmationID = surgeConfirmationID
+
+	return c.bookRide(ctx, payload)
+}
+
+// ConfirmSurge has the user accept the surge pricing of `surgeErr`, and then requests the
+// ride again with `ResubmitRequest`.
+//
+// It opens `surgeErr.Href` in the user's browser and boots up a server that receives the
+// redirect Uber then makes to `redirect`, which must be your app's surge confirmation
+// redirect URL, eg: "http://localhost:7635/surge". The server shuts down once it has
+// handled the redirect (or the context is done). The options are those of
+// `AutOAuthContext`: with `WithHeadless`, the user is asked for the URL they are
+// redirected to, or just the surge confirmation ID in it, instead.
+func (c *Client) ConfirmSurge(
+	ctx context.Context, surgeErr *SurgeRequiredError, redirect string, opts ...AuthOption,
+) (*Request, error) {
+	config := &authFlowConfig{browser: SystemBrowser}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	var surgeConfirmationID string
+	if config.headless {
+		fmt.Fprintf(config.out,
+			"Go to the following URL to accept the surge pricing:\n\n%s\n\n", surgeErr.Href)
+		fmt.Fprint(config.out,
+			"Then paste the URL you were redirected to (or its surge confirmation ID): ")
+
+		line, err := readLine(ctx, config.in)
+		if err != nil {
+			return nil, err
+		}
+
+		if surgeConfirmationID, err = parseSurgeConfirmation(line); err != nil {
+			return nil, err
+		}
+	} else {
+		server, err := newCallbackServer(ctx, redirect, config.listener,
+			func(ctx context.Context, query url.Values) error {
+				if e := query.Get("error"); e != "" {
+					return &authError{Err: e}
+				}
+				if query.Get("surge_confirmation_id") == "" {
+					return errors.New("uber: no surge confirmation ID")
+				}
+
+				surgeConfirmationID = query.Get("surge_confirmation_id")
+				return nil
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := config.browser.Open(surgeErr.Href); err != nil {
+			server.close()
+			return nil, err
+		}
+
+		if err := server.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.ResubmitRequest(ctx, surgeErr, surgeConfirmationID)
+}
+
+// parseSurgeConfirmation returns the surge confirmation ID in `input`, which is either the
+// URL Uber redirected the user to or just the ID.
+func parseSurgeConfirmation(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", errors.New("uber: no surge confirmation ID")
+	}
+
+	if !strings.ContainsAny(input, "?=&/") {
+		return input, nil
+	}
+
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	if u.RawQuery == "" {
+		if query, err = url.ParseQuery(input); err != nil {
+			return "", err
+		}
+	}
+
+	if e := query.Get("error"); e != "" {
+		return "", &authError{Err: e}
+	}
+	if query.Get("surge_confirmation_id") == "" {
+		return "", errors.New("uber: no surge confirmation ID")
+	}
+
+	return query.Get("surge_confirmation_id"), nil
+}
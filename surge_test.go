@@ -0,0 +1,154 @@
+package uber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSurgeServer turns down ride requests without a surge confirmation ID, and books the
+// ones with it. The confirmation IDs it is sent go to `confirmations`.
+func newSurgeServer(confirmations chan<- string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			var body struct {
+				ProductID           string `json:"product_id"`
+				SurgeConfirmationID string `json:"surge_confirmation_id"`
+			}
+			b, _ := io.ReadAll(req.Body)
+			json.Unmarshal(b, &body)
+
+			if body.SurgeConfirmationID == "" {
+				rw.WriteHeader(http.StatusConflict)
+				rw.Write([]byte(`{"meta": {"surge_confirmation": {
+"href": "https://api.uber.com/v1/surge-confirmations/7d604f5e",
+"surge_confirmation_id": "7d604f5e", "multiplier": 1.4, "expires_at": 1429306470}},
+"errors": [{"status": 409, "code": "surge", "title": "Surge pricing is in effect."}]}`))
+				return
+			}
+
+			confirmations <- body.SurgeConfirmationID
+			rw.Write([]byte(`{"request_id": "852b8fdd", "status": "processing"}`))
+		},
+	))
+}
+
+func TestSurgeRequiredError(t *testing.T) {
+	t.Parallel()
+
+	confirmations := make(chan string, 1)
+	server := newSurgeServer(confirmations)
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
+
+	_, err := client.PostRequestContext(
+		context.Background(), "a1111c8c", 37.775, -122.417, 37.786, -122.402, "",
+	)
+	var surgeErr *SurgeRequiredError
+	if !errors.As(err, &surgeErr) {
+		t.Fatalf("expected a SurgeRequiredError, got %v", err)
+	}
+	if surgeErr.Multiplier != 1.4 || surgeErr.SurgeConfirmationID != "7d604f5e" ||
+		surgeErr.Href != "https://api.uber.com/v1/surge-confirmations/7d604f5e" ||
+		surgeErr.ExpiresAt.Unix() != 1429306470 {
+		t.Fatalf("unexpected error %+v", surgeErr)
+	}
+	if uberErr, ok := errors.Unwrap(err).(uberError); !ok || uberErr.Code != "surge" ||
+		statusCode(err) != http.StatusConflict {
+		t.Fatalf("expected the api's error, got %v", errors.Unwrap(err))
+	}
+
+	request, err := client.ResubmitRequest(context.Background(), surgeErr, "7d604f5e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.RequestID != "852b8fdd" || <-confirmations != "7d604f5e" {
+		t.Fatalf("expected the ride to be booked with the confirmation, got %+v", request)
+	}
+}
+
+func TestConfirmSurge(t *testing.T) {
+	t.Parallel()
+
+	confirmations := make(chan string, 1)
+	server := newSurgeServer(confirmations)
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
+
+	_, err := client.PostRequestContext(
+		context.Background(), "a1111c8c", 37.775, -122.417, 37.786, -122.402, "",
+	)
+	var surgeErr *SurgeRequiredError
+	if !errors.As(err, &surgeErr) {
+		t.Fatalf("expected a SurgeRequiredError, got %v", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirect := "http://" + l.Addr().String() + "/surge"
+
+	// accepting the surge pricing redirects the user with the confirmation ID
+	browser := BrowserFunc(func(href string) error {
+		if href != surgeErr.Href {
+			t.Errorf("expected %s to be opened, got %s", surgeErr.Href, href)
+		}
+		go http.Get(redirect + "?surge_confirmation_id=7d604f5e")
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request, err := client.ConfirmSurge(
+		ctx, surgeErr, redirect, WithListener(l), WithBrowser(browser),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.RequestID != "852b8fdd" || <-confirmations != "7d604f5e" {
+		t.Fatalf("expected the ride to be booked with the confirmation, got %+v", request)
+	}
+
+	// or the user pastes the URL they were redirected to
+	terminal := strings.NewReader(redirect + "?surge_confirmation_id=9e0a7b2c\n")
+	request, err = client.ConfirmSurge(
+		ctx, surgeErr, redirect, WithHeadless(terminal, io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.RequestID != "852b8fdd" || <-confirmations != "9e0a7b2c" {
+		t.Fatalf("expected the ride to be booked with the confirmation, got %+v", request)
+	}
+}
+
+func TestParseSurgeConfirmation(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		input string
+		id    string
+		err   bool
+	}{
+		{input: "7d604f5e\n", id: "7d604f5e"},
+		{input: "http://localhost/surge?surge_confirmation_id=7d604f5e", id: "7d604f5e"},
+		{input: "surge_confirmation_id=7d604f5e", id: "7d604f5e"},
+		{input: "http://localhost/surge?error=access_denied", err: true},
+		{input: "http://localhost/surge", err: true},
+		{input: "  ", err: true},
+	} {
+		id, err := parseSurgeConfirmation(test.input)
+		if (err != nil) != test.err || id != test.id {
+			t.Fatalf("%q: expected %q (error: %t), got %q, %v",
+				test.input, test.id, test.err, id, err)
+		}
+	}
+}
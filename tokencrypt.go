@@ -0,0 +1,295 @@
+package uber
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrDecryptToken is wrapped by the `DecryptError` returned when a stored token cannot
+// be decrypted, eg: because the key is wrong or the data has been tampered with.
+var ErrDecryptToken = errors.New("wrong key or corrupted data")
+
+// ErrUnknownKey is wrapped by the `DecryptError` returned when a stored token was
+// encrypted with a key the store doesn't have.
+var ErrUnknownKey = errors.New("unknown key")
+
+// DecryptError is returned by `EncryptedTokenStore.Load` when the token stored for a
+// user cannot be decrypted.
+type DecryptError struct {
+	// The user whose token could not be decrypted
+	User string
+
+	// The ID of the key the token was encrypted with, if known
+	KeyID string
+
+	// eg: `ErrDecryptToken`, `ErrUnknownKey`
+	Err error
+}
+
+// Error implements the `error` interface for `DecryptError`.
+func (err *DecryptError) Error() string {
+	if err.KeyID == "" {
+		return fmt.Sprintf("uber: could not decrypt token for %q: %v", err.User, err.Err)
+	}
+
+	return fmt.Sprintf(
+		"uber: could not decrypt token for %q with key %q: %v", err.User, err.KeyID, err.Err,
+	)
+}
+
+// Unwrap returns the underlying error.
+func (err *DecryptError) Unwrap() error {
+	return err.Err
+}
+
+// scrypt parameters, as recommended for interactive logins in 2017
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptSaltSz = 16
+	keySize      = 32 // AES-256
+)
+
+// EncryptionKey is a key that an `EncryptedTokenStore` encrypts tokens with. Use
+// `PassphraseKey` or `KeyFromFile` to get one.
+type EncryptionKey struct {
+	// Identifies the key in encrypted records, so that the store knows which key to
+	// decrypt them with after a rotation. It is not secret.
+	ID string
+
+	// exactly one of the two is set
+	passphrase []byte
+	key        []byte
+}
+
+// PassphraseKey returns a key derived from `passphrase` with scrypt. A fresh salt is
+// used for every token that is saved.
+func PassphraseKey(id, passphrase string) EncryptionKey {
+	return EncryptionKey{ID: id, passphrase: []byte(passphrase)}
+}
+
+// KeyFromFile reads a 256 bit key from the file at `path`. The file must hold either
+// the 32 raw bytes of the key, and nothing else, or their 64 character hex encoding,
+// which may be surrounded by whitespace. Which one it is is told by its length, so that
+// a raw key made only of hex digits isn't taken for a hex encoded one.
+func KeyFromFile(id, path string) (EncryptionKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return EncryptionKey{}, err
+	}
+
+	if len(data) == keySize {
+		return EncryptionKey{ID: id, key: data}, nil
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != keySize {
+		return EncryptionKey{}, fmt.Errorf(
+			"uber: key file %s must contain %d raw or hex encoded bytes", path, keySize,
+		)
+	}
+
+	return EncryptionKey{ID: id, key: key}, nil
+}
+
+// aead returns the cipher for this key. Passphrase keys are derived with `salt`.
+func (k EncryptionKey) aead(salt []byte) (cipher.AEAD, error) {
+	key := k.key
+	if k.passphrase != nil {
+		var err error
+		key, err = scrypt.Key(k.passphrase, salt, scryptN, scryptR, scryptP, keySize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encryptedToken is how an `EncryptedTokenStore` serializes a token before handing it
+// to the underlying store.
+type encryptedToken struct {
+	Version    int    `json:"v"`
+	KeyID      string `json:"kid"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"data"`
+}
+
+// EncryptedTokenStore is a `TokenStore` that encrypts tokens with AES-256-GCM before
+// handing them to another `TokenStore`, usually a `FileTokenStore`. Each token is bound
+// to its user, so swapping files between users makes decryption fail.
+//
+// Keys can be rotated by creating the store with the new key as primary and the old
+// ones after it. Tokens encrypted with an old key are still loaded, and re-encrypted
+// with the primary key the next time they are saved, eg: once they are refreshed, or
+// right away with `Rotate`.
+type EncryptedTokenStore struct {
+	store   TokenStore
+	primary EncryptionKey
+	keys    map[string]EncryptionKey
+}
+
+// NewEncryptedTokenStore returns a store that encrypts tokens with `primary` and keeps
+// them in `store`. The `old` keys are only used to decrypt tokens saved before a
+// rotation.
+func NewEncryptedTokenStore(
+	store TokenStore, primary EncryptionKey, old ...EncryptionKey,
+) (*EncryptedTokenStore, error) {
+	s := &EncryptedTokenStore{
+		store:   store,
+		primary: primary,
+		keys:    make(map[string]EncryptionKey),
+	}
+
+	for _, key := range append([]EncryptionKey{primary}, old...) {
+		switch {
+		case key.ID == "":
+			return nil, errors.New("uber: encryption keys need an ID")
+		case len(key.passphrase) == 0 && key.key == nil:
+			return nil, fmt.Errorf("uber: encryption key %q is empty", key.ID)
+		}
+		if _, ok := s.keys[key.ID]; ok {
+			return nil, fmt.Errorf("uber: duplicate encryption key ID %q", key.ID)
+		}
+
+		s.keys[key.ID] = key
+	}
+
+	return s, nil
+}
+
+// NewEncryptedFileTokenStore is a shortcut for an `EncryptedTokenStore` on top of a
+// `FileTokenStore` in `dir`.
+func NewEncryptedFileTokenStore(
+	dir string, primary EncryptionKey, old ...EncryptionKey,
+) (*EncryptedTokenStore, error) {
+	return NewEncryptedTokenStore(NewFileTokenStore(dir), primary, old...)
+}
+
+// Load implements `TokenStore`. Failures to decrypt are reported as a `*DecryptError`.
+func (s *EncryptedTokenStore) Load(user string) ([]byte, error) {
+	data, err := s.store.Load(user)
+	if err != nil {
+		return nil, err
+	}
+
+	token, _, err := s.decrypt(user, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// Save implements `TokenStore`.
+func (s *EncryptedTokenStore) Save(user string, token []byte) error {
+	record := encryptedToken{Version: 1, KeyID: s.primary.ID}
+	if s.primary.passphrase != nil {
+		record.Salt = make([]byte, scryptSaltSz)
+		if _, err := rand.Read(record.Salt); err != nil {
+			return err
+		}
+	}
+
+	aead, err := s.primary.aead(record.Salt)
+	if err != nil {
+		return err
+	}
+
+	record.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(record.Nonce); err != nil {
+		return err
+	}
+	record.Ciphertext = aead.Seal(nil, record.Nonce, token, additionalData(user, record.KeyID))
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Save(user, data)
+}
+
+// Delete implements `TokenStore`.
+func (s *EncryptedTokenStore) Delete(user string) error {
+	return s.store.Delete(user)
+}
+
+// Rotate re-encrypts the tokens of `users` with the primary key. Once every user has
+// been rotated, the old keys can be dropped.
+func (s *EncryptedTokenStore) Rotate(users ...string) error {
+	for _, user := range users {
+		data, err := s.store.Load(user)
+		if err == ErrTokenNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		token, keyID, err := s.decrypt(user, data)
+		if err != nil {
+			return err
+		}
+		if keyID == s.primary.ID {
+			continue
+		}
+
+		if err := s.Save(user, token); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decrypt returns the plaintext token in `data` and the ID of the key it was encrypted
+// with.
+func (s *EncryptedTokenStore) decrypt(user string, data []byte) ([]byte, string, error) {
+	record := new(encryptedToken)
+	if err := json.Unmarshal(data, record); err != nil || record.Version != 1 {
+		return nil, "", &DecryptError{User: user, Err: ErrDecryptToken}
+	}
+
+	key, ok := s.keys[record.KeyID]
+	if !ok {
+		return nil, "", &DecryptError{User: user, KeyID: record.KeyID, Err: ErrUnknownKey}
+	}
+
+	aead, err := key.aead(record.Salt)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(record.Nonce) != aead.NonceSize() {
+		return nil, "", &DecryptError{User: user, KeyID: record.KeyID, Err: ErrDecryptToken}
+	}
+
+	token, err := aead.Open(
+		nil, record.Nonce, record.Ciphertext, additionalData(user, record.KeyID),
+	)
+	if err != nil {
+		return nil, "", &DecryptError{User: user, KeyID: record.KeyID, Err: ErrDecryptToken}
+	}
+
+	return token, record.KeyID, nil
+}
+
+// additionalData binds a ciphertext to the user and key it belongs to.
+func additionalData(user, keyID string) []byte {
+	return []byte(fmt.Sprintf("go-uber token v1\x00%s\x00%s", user, keyID))
+}
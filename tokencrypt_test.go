@@ -0,0 +1,174 @@
+package uber
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedTokenStore(t *testing.T) {
+	t.Parallel()
+
+	store, err := NewEncryptedTokenStore(
+		NewMemoryTokenStore(), PassphraseKey("2017", "correct horse battery staple"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testTokenStore(t, store)
+}
+
+func TestEncryptedTokenStoreAtRest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyPath, []byte(strings.Repeat("ab", 32)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := KeyFromFile("file", keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewEncryptedFileTokenStore(filepath.Join(dir, "tokens"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("rider", []byte(`{"refresh_token": "secret"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := NewFileTokenStore(filepath.Join(dir, "tokens")).Load("rider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "secret") {
+		t.Fatal("token is stored in plaintext")
+	}
+
+	// the ciphertext is bound to its user
+	if err := NewFileTokenStore(filepath.Join(dir, "tokens")).Save("mallory", raw); err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Load("mallory")
+	var decryptErr *DecryptError
+	if !errors.As(err, &decryptErr) || !errors.Is(err, ErrDecryptToken) {
+		t.Fatalf("expected a DecryptError, got %v", err)
+	}
+	if decryptErr.User != "mallory" || decryptErr.KeyID != "file" {
+		t.Fatalf("unexpected error details %+v", decryptErr)
+	}
+}
+
+func TestEncryptedTokenStoreWrongKey(t *testing.T) {
+	t.Parallel()
+
+	backing := NewMemoryTokenStore()
+	store, _ := NewEncryptedTokenStore(backing, PassphraseKey("k", "right"))
+	if err := store.Save("rider", []byte("token")); err != nil {
+		t.Fatal(err)
+	}
+
+	wrong, _ := NewEncryptedTokenStore(backing, PassphraseKey("k", "wrong"))
+	if _, err := wrong.Load("rider"); !errors.Is(err, ErrDecryptToken) {
+		t.Fatalf("expected ErrDecryptToken, got %v", err)
+	}
+
+	unknown, _ := NewEncryptedTokenStore(backing, PassphraseKey("other", "right"))
+	if _, err := unknown.Load("rider"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestEncryptedTokenStoreRotation(t *testing.T) {
+	t.Parallel()
+
+	backing := NewMemoryTokenStore()
+	oldKey := PassphraseKey("old", "old passphrase")
+	newKey := EncryptionKey{ID: "new", key: make([]byte, keySize)}
+
+	old, _ := NewEncryptedTokenStore(backing, oldKey)
+	if err := old.Save("rider", []byte("token")); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := NewEncryptedTokenStore(backing, newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rotated.Rotate("rider", "nobody"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the old key is no longer needed
+	current, _ := NewEncryptedTokenStore(backing, newKey)
+	token, err := current.Load("rider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(token) != "token" {
+		t.Fatalf("expected %q, got %q", "token", token)
+	}
+}
+
+func TestEncryptedTokenStoreReadOnly(t *testing.T) {
+	t.Parallel()
+
+	backing := NewMemoryTokenStore()
+	oldKey := PassphraseKey("old", "old passphrase")
+	newKey := EncryptionKey{ID: "new", key: make([]byte, keySize)}
+
+	old, _ := NewEncryptedTokenStore(backing, oldKey)
+	if err := old.Save("rider", []byte("token")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a token under an old key loads even if it can't be written back...
+	rotated, _ := NewEncryptedTokenStore(failingTokenStore{backing}, newKey, oldKey)
+	token, err := rotated.Load("rider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(token) != "token" {
+		t.Fatalf("expected %q, got %q", "token", token)
+	}
+
+	// ...and rotating it reports why it can't
+	if err := rotated.Rotate("rider"); !errors.Is(err, errSaveFailed) {
+		t.Fatalf("expected %v, got %v", errSaveFailed, err)
+	}
+}
+
+func TestKeyFromFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	raw := filepath.Join(dir, "raw")
+	os.WriteFile(raw, make([]byte, keySize), 0600)
+	if _, err := KeyFromFile("raw", raw); err != nil {
+		t.Fatal(err)
+	}
+
+	// a raw key that happens to be made of hex digits isn't decoded
+	hexDigits := filepath.Join(dir, "hex_digits")
+	os.WriteFile(hexDigits, []byte(strings.Repeat("ab", keySize/2)), 0600)
+	key, err := KeyFromFile("hex_digits", hexDigits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(key.key) != strings.Repeat("ab", keySize/2) {
+		t.Fatalf("expected the raw key, got %x", key.key)
+	}
+
+	short := filepath.Join(dir, "short")
+	os.WriteFile(short, []byte(hex.EncodeToString(make([]byte, 16))+"\n"), 0600)
+	if _, err := KeyFromFile("short", short); err == nil {
+		t.Fatal("expected an error for a 128 bit key")
+	}
+}
@@ -0,0 +1,240 @@
+package uber
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrTokenNotFound is returned by a `TokenStore` when it holds no token for a user.
+var ErrTokenNotFound = errors.New("uber: no token stored for user")
+
+// TokenStore persists the OAuth credentials of users so that they survive restarts of
+// the process and users don't have to go through the authorization flow again. The
+// tokens are opaque to the store: `Client` serializes them before calling `Save` and
+// deserializes what `Load` returns.
+//
+// Implementations must be safe for concurrent use.
+type TokenStore interface {
+	// Load returns the token saved for `user`, or `ErrTokenNotFound`.
+	Load(user string) ([]byte, error)
+
+	// Save stores the token for `user`, replacing any previous one.
+	Save(user string, token []byte) error
+
+	// Delete removes the token for `user`. Deleting a token that doesn't exist is not
+	// an error.
+	Delete(user string) error
+}
+
+// TokenSaveError is returned when the client got a new token but its `TokenStore`
+// could not save it. The client uses the new token all the same.
+type TokenSaveError struct {
+	// The user the token is for
+	User string
+
+	// The error of the store
+	Err error
+}
+
+// Error implements the `error` interface for `TokenSaveError`.
+func (err *TokenSaveError) Error() string {
+	return fmt.Sprintf("uber: could not save token: %v", err.Err)
+}
+
+// Unwrap returns the error of the store.
+func (err *TokenSaveError) Unwrap() error {
+	return err.Err
+}
+
+// SetTokenStore makes the client persist its tokens for `user` in `store` whenever it
+// gets new ones, ie: after `SetAccessToken` and after refreshing. If the store already
+// has a token for `user`, the client starts using it right away.
+//
+// If the store fails to save a token, `SetAccessToken` and `Exchange` return a
+// `*TokenSaveError`, and the client keeps the token in memory. A token refreshed during
+// a call to the api doesn't fail the call: the error goes to the callback registered
+// with `OnTokenSaveError`, if any.
+//
+// In order for a token that was loaded from a store to be refreshed, the client needs
+// its credentials (see `Client.SetCredentials`).
+func (c *Client) SetTokenStore(store TokenStore, user string) error {
+	c.tokenStore = store
+	c.tokenUser = user
+
+	data, err := store.Load(user)
+	if err == ErrTokenNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	access := new(access)
+	if err := json.Unmarshal(data, access); err != nil {
+		return fmt.Errorf("uber: could not decode stored token: %v", err)
+	}
+	c.setAccess(access)
+
+	return nil
+}
+
+// OnTokenSaveError registers a callback for the `*TokenSaveError`s of the tokens that
+// are refreshed during calls to the api. Register it before making any.
+func (c *Client) OnTokenSaveError(f func(error)) {
+	c.onTokenSaveError = f
+}
+
+// saveAccess writes `access` to the client's token store, if it has one. The error, if
+// any, is a `*TokenSaveError`.
+func (c *Client) saveAccess(access *access) error {
+	if c.tokenStore == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(access)
+	if err != nil {
+		return err
+	}
+
+	if err := c.tokenStore.Save(c.tokenUser, data); err != nil {
+		return &TokenSaveError{User: c.tokenUser, Err: err}
+	}
+
+	return nil
+}
+
+// MemoryTokenStore is a `TokenStore` that keeps tokens in memory. It is mostly useful
+// for tests and for sharing tokens between clients within a process.
+type MemoryTokenStore struct {
+	mu     sync.RWMutex
+	tokens map[string][]byte
+}
+
+// NewMemoryTokenStore returns an empty `MemoryTokenStore`.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: make(map[string][]byte)}
+}
+
+// Load implements `TokenStore`.
+func (s *MemoryTokenStore) Load(user string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, ok := s.tokens[user]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+
+	return append([]byte(nil), token...), nil
+}
+
+// Save implements `TokenStore`.
+func (s *MemoryTokenStore) Save(user string, token []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[user] = append([]byte(nil), token...)
+	return nil
+}
+
+// Delete implements `TokenStore`.
+func (s *MemoryTokenStore) Delete(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, user)
+	return nil
+}
+
+// FileTokenStore is a `TokenStore` that keeps one file per user in a directory. Files
+// are only readable by their owner and are replaced atomically, so a crash while
+// saving never leaves a half-written token behind.
+type FileTokenStore struct {
+	dir string
+}
+
+// NewFileTokenStore returns a `FileTokenStore` that keeps its files in `dir`. The
+// directory is created on the first save if it doesn't exist.
+func NewFileTokenStore(dir string) *FileTokenStore {
+	return &FileTokenStore{dir: dir}
+}
+
+// path returns the name of the file holding the token for `user`.
+func (s *FileTokenStore) path(user string) (string, error) {
+	if user == "" {
+		return "", errors.New("uber: token store user cannot be empty")
+	}
+
+	// escaping keeps users like "../x" inside the directory
+	return filepath.Join(s.dir, url.PathEscape(user)+".json"), nil
+}
+
+// Load implements `TokenStore`.
+func (s *FileTokenStore) Load(user string) ([]byte, error) {
+	path, err := s.path(user)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrTokenNotFound
+	}
+
+	return token, err
+}
+
+// Save implements `TokenStore`. The token is written to a temporary file in the same
+// directory, which is then renamed over the old one.
+func (s *FileTokenStore) Save(user string, token []byte) error {
+	path, err := s.path(user)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".token-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(token); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Delete implements `TokenStore`.
+func (s *FileTokenStore) Delete(user string) error {
+	path, err := s.path(user)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
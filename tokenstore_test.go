@@ -0,0 +1,166 @@
+package uber
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testTokenStore(t *testing.T, store TokenStore) {
+	if _, err := store.Load("rider"); err != ErrTokenNotFound {
+		t.Fatalf("expected ErrTokenNotFound, got %v", err)
+	}
+
+	for _, token := range []string{"first", "second"} {
+		if err := store.Save("rider", []byte(token)); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := store.Load("rider")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(loaded) != token {
+			t.Fatalf("expected %q, got %q", token, loaded)
+		}
+	}
+
+	if err := store.Delete("rider"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load("rider"); err != ErrTokenNotFound {
+		t.Fatalf("expected ErrTokenNotFound after delete, got %v", err)
+	}
+	if err := store.Delete("rider"); err != nil {
+		t.Fatalf("deleting a missing token should not fail, got %v", err)
+	}
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	t.Parallel()
+
+	testTokenStore(t, NewMemoryTokenStore())
+}
+
+func TestFileTokenStore(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "tokens")
+	store := NewFileTokenStore(dir)
+	testTokenStore(t, store)
+
+	if err := store.Save("../rider", []byte("token")); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected a single file in the store, got %d", len(entries))
+	}
+
+	info, err := entries[0].Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("expected permissions 0600, got %o", perm)
+	}
+}
+
+func TestClientTokenStore(t *testing.T) {
+	t.Parallel()
+
+	var refreshes int32
+	authServer := newTestAuthServer(t, &refreshes)
+	defer authServer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(getUserProfileHandler))
+	defer server.Close()
+
+	store := NewMemoryTokenStore()
+
+	// the refreshed token is saved...
+	client := newTestRefreshClient(
+		time.Now().Add(-2592000*time.Second), WithAPIHost(server.URL), WithAuthHost(authServer.URL),
+	)
+	if err := client.SetTokenStore(store, "rider"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetUserProfile(); err != nil {
+		t.Fatal(err)
+	}
+
+	// ...and picked up by a new client
+	restored := NewClient(testServerToken)
+	if err := restored.SetTokenStore(store, "rider"); err != nil {
+		t.Fatal(err)
+	}
+
+	access := restored.currentAccess()
+	if access.Token != "fresh_token" || access.RefreshToken != "refresh_token" {
+		t.Fatalf("unexpected restored token %+v", access)
+	}
+	if access.IssuedAt.IsZero() {
+		t.Fatal("expected the restored token to know when it was issued")
+	}
+}
+
+// failingTokenStore is a `TokenStore` that can't save tokens.
+type failingTokenStore struct {
+	*MemoryTokenStore
+}
+
+var errSaveFailed = errors.New("disk full")
+
+func (failingTokenStore) Save(user string, token []byte) error {
+	return errSaveFailed
+}
+
+func TestClientTokenStoreSaveError(t *testing.T) {
+	t.Parallel()
+
+	var refreshes int32
+	authServer := newTestAuthServer(t, &refreshes)
+	defer authServer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(getUserProfileHandler))
+	defer server.Close()
+
+	client := newTestRefreshClient(
+		time.Now().Add(-2592000*time.Second), WithAPIHost(server.URL), WithAuthHost(authServer.URL),
+	)
+	if err := client.SetTokenStore(failingTokenStore{NewMemoryTokenStore()}, "rider"); err != nil {
+		t.Fatal(err)
+	}
+	var saveErrs []error
+	client.OnTokenSaveError(func(err error) { saveErrs = append(saveErrs, err) })
+
+	// the call goes through with the refreshed token, which is kept in memory
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetUserProfile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&refreshes); n != 1 {
+		t.Fatalf("expected 1 refresh, got %d", n)
+	}
+	if token := client.currentAccess().Token; token != "fresh_token" {
+		t.Fatalf("expected the refreshed token, got %q", token)
+	}
+
+	var saveErr *TokenSaveError
+	if len(saveErrs) != 1 || !errors.As(saveErrs[0], &saveErr) || saveErr.User != "rider" {
+		t.Fatalf("expected a TokenSaveError for rider, got %v", saveErrs)
+	}
+	if !errors.Is(saveErrs[0], errSaveFailed) {
+		t.Fatalf("expected the store's error, got %v", saveErrs[0])
+	}
+}
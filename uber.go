@@ -2,11 +2,17 @@ package uber
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"time"
 )
 
 const (
 	Version         = "v1"
+	FareVersion     = "v1.2" // the version of the api that has upfront fares
 	RequestEndpoint = "requests"
 	ProductEndpoint = "products"
 	PriceEndpoint   = "estimates/price"
@@ -14,40 +20,80 @@ const (
 	HistoryEndpoint = "history"
 	UserEndpoint    = "me"
 
-	// request statuses
+	CurrentRequestEndpoint  = "requests/current"
+	RequestEstimateEndpoint = "requests/estimate"
+
+	// only available in sandbox mode (see `WithSandbox`)
+	SandboxRequestEndpoint = "sandbox/requests"
+	SandboxProductEndpoint = "sandbox/products"
+
+	// request statuses (see `RideStatus`)
 
 	// The `Request` is matching to the most efficient available driver.
-	StatusProcessing = "processing"
+	StatusProcessing RideStatus = "processing"
 	// The `Request` was unfulfilled because no drivers were available.
-	StatusNoDrivers = "no_drivers_available"
+	StatusNoDrivers RideStatus = "no_drivers_available"
 	// The `Request` has been accepted by a driver and is "en route" to the
 	// start_location.
-	StatusAccepted = "accepted"
+	StatusAccepted RideStatus = "accepted"
 	// The driver has arrived or will be shortly.
-	StatusArriving = "arriving"
+	StatusArriving RideStatus = "arriving"
 	// The `Request` is "en route" from the start location to the end location.
-	StatusInProgress = "in_progress"
+	StatusInProgress RideStatus = "in_progress"
 	// The `Request` has been canceled by the driver.
-	StatusDriverCanceled = "driver_canceled"
+	StatusDriverCanceled RideStatus = "driver_canceled"
 	// The `Request` has been canceled by the rider.
-	StatusRiderCanceled = "rider_canceled"
+	StatusRiderCanceled RideStatus = "rider_canceled"
 	// The `Request` has been completed by the driver.
-	StatusCompleted = "completed"
+	StatusCompleted RideStatus = "completed"
 
 	// the next two use `AUTH_EDPOINT`
 
 	AccessCodeEndpoint  = "authorize"
 	AccessTokenEndpoint = "token"
 
+	// Deprecated: `OAuth` now generates a random state for every authorization
+	// attempt. This constant is no longer sent or accepted.
 	State = "go-uber"
-	Port  = ":7635"
+
+	Port = ":7635"
 )
 
-// declared as vars so that unit tests can edit the values and hit internal test server
+// Scope is a permission your app asks users for when they authorize it. Each endpoint
+// that acts on behalf of a user needs one.
+// https://developer.uber.com/docs/riders/guides/scopes
+type Scope string
+
+const (
+	// Access the basic profile information on a user's Uber account including their
+	// first name, email address, and profile picture.
+	ScopeProfile Scope = "profile"
+	// Pull trip data including the locations, times, and product type of a user's
+	// historical pickups and drop-offs.
+	ScopeHistory Scope = "history"
+	// Same as `ScopeHistory` but without the pickup and drop-off locations.
+	ScopeHistoryLite Scope = "history_lite"
+	// Save and retrieve the user's favorite places (home and work).
+	ScopePlaces Scope = "places"
+	// Make requests for Uber rides on behalf of the user.
+	ScopeRequest Scope = "request"
+	// Get receipt details for requests made by the application.
+	ScopeRequestReceipt Scope = "request_receipt"
+	// Get details of the user's trips, including those not made by the application.
+	ScopeAllTrips Scope = "all_trips"
+	// Show the user's current trip in the ride request widget.
+	ScopeRideWidgets Scope = "ride_widgets"
+)
+
+// The hosts new clients talk to by default. Use `WithAPIHost`, `WithAuthHost` and
+// `WithSandboxHost` to point a client somewhere else.
 var (
 	UberAPIHost = fmt.Sprintf("https://api.uber.com/%s", Version)
 	AuthHost    = "https://login.uber.com/oauth"
 
+	// The root of the sandbox-only endpoints, eg: `SandboxRequestEndpoint` is under
+	// "https://sandbox-api.uber.com/v1/sandbox/requests". Clients in sandbox mode send
+	// the ride request endpoints to the host above it, "https://sandbox-api.uber.com/v1".
 	UberSandboxAPIHost = fmt.Sprintf("https://sandbox-api.uber.com/%s/sandbox", Version)
 )
 
@@ -58,13 +104,38 @@ var (
 // Request contains the information relating to a request for an Uber done on behalf of a
 // user.
 type Request struct {
-	RequestID       string `json:"request_id"`
-	Status          string `json:"status"`
+	RequestID       string     `json:"request_id"`
+	Status          RideStatus `json:"status"`
 	Vehicle         `json:"vehicle"`
 	Driver          `json:"driver"`
 	Location        `json:"location"`
-	ETA             int     `json:"eta"`
+	ETADuration     Minutes `json:"eta"`
 	SurgeMultiplier float64 `json:"surge_multiplier"`
+
+	// Deprecated: use `ETADuration`. The ETA in minutes.
+	ETA int `json:"-"`
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`. It also sets the deprecated `ETA`.
+func (r *Request) UnmarshalJSON(data []byte) error {
+	type request Request
+	if err := json.Unmarshal(data, (*request)(r)); err != nil {
+		return err
+	}
+	r.ETA = int(r.ETADuration.Duration / time.Minute)
+
+	return nil
+}
+
+// MarshalJSON implements `json.Marshaler`. The deprecated `ETA` is used if
+// `ETADuration` isn't set.
+func (r Request) MarshalJSON() ([]byte, error) {
+	type request Request
+	if r.ETADuration.Duration == 0 {
+		r.ETADuration.Duration = time.Duration(r.ETA) * time.Minute
+	}
+
+	return json.Marshal(request(r))
 }
 
 // Vehicle represents the car in a response to requesting a ride.
@@ -147,9 +218,81 @@ type Time struct {
 	// eg: "UberBLACK"
 	DisplayName string `json:"display_name"`
 
-	// The ETA in seconds
+	// The ETA
 	// eg: 410, ie: 6 minutes and 50 seconds
-	Estimate int `json:"estimate"`
+	EstimateDuration Seconds `json:"estimate"`
+
+	// Deprecated: use `EstimateDuration`. The ETA in seconds.
+	Estimate int `json:"-"`
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`. It also sets the deprecated `Estimate`.
+func (t *Time) UnmarshalJSON(data []byte) error {
+	type estimate Time
+	if err := json.Unmarshal(data, (*estimate)(t)); err != nil {
+		return err
+	}
+	t.Estimate = int(t.EstimateDuration.Duration / time.Second)
+
+	return nil
+}
+
+// MarshalJSON implements `json.Marshaler`. The deprecated `Estimate` is used if
+// `EstimateDuration` isn't set.
+func (t Time) MarshalJSON() ([]byte, error) {
+	type estimate Time
+	if t.EstimateDuration.Duration == 0 {
+		t.EstimateDuration.Duration = time.Duration(t.Estimate) * time.Second
+	}
+
+	return json.Marshal(estimate(t))
+}
+
+// RideEstimate is the upfront estimate of a ride, returned by `EstimateRequest`.
+type RideEstimate struct {
+	// Self explanatory (see `Fare`)
+	Fare Fare `json:"fare"`
+
+	// Self explanatory (see `TripEstimate`)
+	Trip TripEstimate `json:"trip"`
+
+	// How long the car will take to get to the pickup location
+	// eg: 2, ie: 2 minutes
+	PickupEstimate Minutes `json:"pickup_estimate"`
+}
+
+// Fare is the upfront fare of a ride. Book the ride with its `FareID` to be charged it.
+type Fare struct {
+	// eg: 5.73
+	Value float64 `json:"value"`
+
+	// Unique identifier of the fare, to book the ride with (see `RideSpec`)
+	// eg: "d30e732b8bba22c9cdc10513ee86380087cb4a6f89e37ad21ba2a39f3a1ba960"
+	FareID string `json:"fare_id"`
+
+	// When the fare stops being offered
+	// eg: 1476953293
+	ExpiresAt Timestamp `json:"expires_at"`
+
+	// Formatted string of the fare in local currency
+	// eg: "$5.73"
+	Display string `json:"display"`
+
+	// ISO 4217 currency code
+	// eg: "USD"
+	CurrencyCode string `json:"currency_code"`
+}
+
+// TripEstimate is the expected length of a ride.
+type TripEstimate struct {
+	// eg: "mile"
+	DistanceUnit string `json:"distance_unit"`
+
+	// eg: 2.39
+	DistanceEstimate float64 `json:"distance_estimate"`
+
+	// eg: 540, ie: 9 minutes
+	DurationEstimate Seconds `json:"duration_estimate"`
 }
 
 // Location contains a human-readable address as well as the exact coordinates of a location.
@@ -165,8 +308,23 @@ type Location struct {
 	Longitude float64 `json:"longitude"`
 }
 
+// HistoryVersion is a version of the User Activity (history) endpoint. Newer versions
+// return more information about each `Trip`.
+type HistoryVersion string
+
+const (
+	// The version of the rest of the api (see `Version`)
+	HistoryV1 HistoryVersion = Version
+	// Adds `Trip.RequestID` and `Trip.StartCity`
+	HistoryV12 HistoryVersion = "v1.2"
+)
+
 // UserActivity contains data about a user's lifetime activity with Uber.
 type UserActivity struct {
+	// The version of the endpoint this activity was retrieved from. Not part of the
+	// api's response.
+	Version HistoryVersion `json:"-"`
+
 	// How much the list of returned results is offset by (position in pagination)
 	// eg: 0
 	Offset int `json:"offset"`
@@ -192,17 +350,17 @@ type Trip struct {
 	// eg: "7354db54-cc9b-4961-81f2-0094b8e2d215"
 	Uuid string `json:"uuid"`
 
-	// Time in seconds
+	// When the trip was requested
 	// eg: 1401884467
-	RequestTime int `json:"request_time"`
+	RequestedAt Timestamp `json:"request_time"`
 
 	// eg: edf5e5eb-6ae6-44af-bec6-5bdcf1e3ed2c
 	ProductID string `json:"product_id"`
 
-	// String depicting the status of the trip. Don't know what values these could take
+	// The status of the trip (see `RideStatus`). Don't know what values these could take
 	// because the website only shows "completed"
 	// eg: "completed"
-	Status string `json:"status"`
+	Status RideStatus `json:"status"`
 
 	// Distance of request in miles (presumable that of the customer to he nearest driver)
 	// eg: 0.0279562
@@ -210,17 +368,78 @@ type Trip struct {
 
 	// Start time of trip
 	// eg: 1401884646
-	StartTime int `json:"start_time"`
+	StartedAt Timestamp `json:"start_time"`
 
 	// Self explanatory (see `Location`)
 	StartLocation *Location `json:"start_location"`
 
-	// Start time of trip
+	// End time of trip
 	// eg: 1401884732
-	EndTime int `json:"end_time"`
+	EndedAt Timestamp `json:"end_time"`
 
 	// Self explanatory (see `Location`)
 	EndLocation *Location `json:"end_location"`
+
+	// The following are only returned by `HistoryV12`
+
+	// Unique identifier of the ride request
+	// eg: "37d57a99-2647-4114-9dd2-c43bccf4c30b"
+	RequestID string `json:"request_id,omitempty"`
+
+	// The city the trip started in (see `City`)
+	StartCity *City `json:"start_city,omitempty"`
+
+	// Deprecated: use `RequestedAt`. Time in seconds.
+	RequestTime int `json:"-"`
+
+	// Deprecated: use `StartedAt`. Time in seconds.
+	StartTime int `json:"-"`
+
+	// Deprecated: use `EndedAt`. Time in seconds.
+	EndTime int `json:"-"`
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`. It also sets the deprecated `RequestTime`,
+// `StartTime` and `EndTime`.
+func (t *Trip) UnmarshalJSON(data []byte) error {
+	type trip Trip
+	if err := json.Unmarshal(data, (*trip)(t)); err != nil {
+		return err
+	}
+	t.RequestTime = t.RequestedAt.seconds()
+	t.StartTime = t.StartedAt.seconds()
+	t.EndTime = t.EndedAt.seconds()
+
+	return nil
+}
+
+// MarshalJSON implements `json.Marshaler`. The deprecated `RequestTime`, `StartTime`
+// and `EndTime` are used if their replacements aren't set.
+func (t Trip) MarshalJSON() ([]byte, error) {
+	type trip Trip
+	if t.RequestedAt.IsZero() {
+		t.RequestedAt = timestamp(t.RequestTime)
+	}
+	if t.StartedAt.IsZero() {
+		t.StartedAt = timestamp(t.StartTime)
+	}
+	if t.EndedAt.IsZero() {
+		t.EndedAt = timestamp(t.EndTime)
+	}
+
+	return json.Marshal(trip(t))
+}
+
+// City is the city a `Trip` started in.
+type City struct {
+	// eg: "San Francisco"
+	DisplayName string `json:"display_name"`
+
+	// eg: 37.7749
+	Latitude float64 `json:"latitude"`
+
+	// eg: -122.4194
+	Longitude float64 `json:"longitude"`
 }
 
 // User is the response from the /me endpoint. Provides information about the
@@ -244,6 +463,144 @@ type User struct {
 	PromoCode string `json:"promo_code"`
 }
 
+// Timestamp is a time that the api represents as a number of seconds since the Unix
+// epoch. The zero `Timestamp` is an unknown time, which the api represents as 0.
+type Timestamp struct {
+	time.Time
+}
+
+// timestamp returns the `Timestamp` of `sec` seconds since the epoch.
+func timestamp(sec int) Timestamp {
+	if sec == 0 {
+		return Timestamp{}
+	}
+
+	return Timestamp{time.Unix(int64(sec), 0).UTC()}
+}
+
+// seconds returns the number of seconds since the epoch, or 0 for the zero `Timestamp`.
+func (t Timestamp) seconds() int {
+	if t.IsZero() {
+		return 0
+	}
+
+	return int(t.Unix())
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`. The time is in UTC.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	f, isInt, err := parseNumber(data)
+	if err != nil {
+		return fmt.Errorf("uber: invalid timestamp %s", data)
+	}
+
+	switch {
+	case f == 0:
+		*t = Timestamp{}
+	case isInt:
+		*t = timestamp(int(f))
+	default:
+		sec := math.Floor(f)
+		nsec := math.Round((f - sec) * float64(time.Second))
+		*t = Timestamp{time.Unix(int64(sec), int64(nsec)).UTC()}
+	}
+
+	return nil
+}
+
+// MarshalJSON implements `json.Marshaler`.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.Nanosecond() == 0 {
+		return strconv.AppendInt(nil, int64(t.seconds()), 10), nil
+	}
+
+	sec := float64(t.UnixNano()) / float64(time.Second)
+	return strconv.AppendFloat(nil, sec, 'f', -1, 64), nil
+}
+
+// Seconds is a duration that the api represents as a number of seconds.
+type Seconds struct {
+	time.Duration
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`.
+func (s *Seconds) UnmarshalJSON(data []byte) error {
+	return unmarshalDuration(data, time.Second, &s.Duration)
+}
+
+// MarshalJSON implements `json.Marshaler`.
+func (s Seconds) MarshalJSON() ([]byte, error) {
+	return marshalDuration(s.Duration, time.Second), nil
+}
+
+// Minutes is a duration that the api represents as a number of minutes.
+type Minutes struct {
+	time.Duration
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`.
+func (m *Minutes) UnmarshalJSON(data []byte) error {
+	return unmarshalDuration(data, time.Minute, &m.Duration)
+}
+
+// MarshalJSON implements `json.Marshaler`.
+func (m Minutes) MarshalJSON() ([]byte, error) {
+	return marshalDuration(m.Duration, time.Minute), nil
+}
+
+// unmarshalDuration sets `d` to the number of `unit`s in `data`.
+func unmarshalDuration(data []byte, unit time.Duration, d *time.Duration) error {
+	f, isInt, err := parseNumber(data)
+	if err != nil {
+		return fmt.Errorf("uber: invalid duration %s", data)
+	}
+
+	if isInt {
+		*d = time.Duration(f) * unit
+	} else {
+		*d = time.Duration(math.Round(f * float64(unit)))
+	}
+
+	return nil
+}
+
+// marshalDuration returns `d` as a number of `unit`s. Whole numbers are written as
+// integers, like the api does.
+func marshalDuration(d, unit time.Duration) []byte {
+	if d%unit == 0 {
+		return strconv.AppendInt(nil, int64(d/unit), 10)
+	}
+
+	return strconv.AppendFloat(nil, float64(d)/float64(unit), 'f', -1, 64)
+}
+
+// parseNumber parses a JSON number, and reports whether it is an integer. null is 0.
+func parseNumber(data []byte) (f float64, isInt bool, err error) {
+	s := string(data)
+	if s == "null" {
+		return 0, true, nil
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return float64(i), true, nil
+	}
+
+	f, err = strconv.ParseFloat(s, 64)
+	return f, false, err
+}
+
+// ErrMissingScope is returned, before anything is sent to the api, when calling an
+// endpoint that needs a scope the user hasn't granted.
+type ErrMissingScope struct {
+	// The scope the endpoint needs
+	Scope Scope
+}
+
+// Error implements the `error` interface for `ErrMissingScope`.
+func (err *ErrMissingScope) Error() string {
+	return fmt.Sprintf("uber: the %q scope has not been granted", err.Scope)
+}
+
 //
 // internal error types
 //
@@ -263,6 +620,25 @@ type uberError struct {
 	// member strings that describe the specific validation error
 	// eg: map{"first_name": ["Required"]}
 	Fields map[string]string `json:"fields,omitempty"`
+
+	// The HTTP status of the response
+	statusCode int
+}
+
+// statusCode returns the HTTP status of the response `err` came from, if it is an error
+// the api returned, or 0.
+func statusCode(err error) int {
+	var uberErr uberError
+	if errors.As(err, &uberErr) {
+		return uberErr.statusCode
+	}
+
+	var uberErrPtr *uberError
+	if errors.As(err, &uberErrPtr) {
+		return uberErrPtr.statusCode
+	}
+
+	return 0
 }
 
 // Error implements the `error` interface for `uberError`.
@@ -1,15 +1,21 @@
 package uber
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 var (
-	testClient      *Client
 	testServerToken = "some_token"
 	testAccessToken = "bearer_token"
 	testProducts    = map[string][]*Product{
@@ -52,16 +58,19 @@ var (
 		History: []*Trip{
 			&Trip{
 				Uuid:        "7354db54-cc9b-4961-81f2-0094b8e2d215",
+				RequestedAt: timestamp(1401884467),
 				RequestTime: 1401884467,
 				ProductID:   "edf5e5eb-6ae6-44af-bec6-5bdcf1e3ed2c",
 				Status:      "completed",
 				Distance:    0.0279562,
+				StartedAt:   timestamp(1401884646),
 				StartTime:   1401884646,
 				StartLocation: &Location{
 					Address:   "706 Mission St, San Francisco, CA",
 					Latitude:  37.7860099,
 					Longitude: -122.4025387,
 				},
+				EndedAt: timestamp(1401884732),
 				EndTime: 1401884732,
 				EndLocation: &Location{
 					Address:   "1455 Market Street, San Francisco, CA",
@@ -80,26 +89,66 @@ var (
 	}
 )
 
-// TODO(r-medina): update test
 func TestNewClient(t *testing.T) {
-	testClient = NewClient(testServerToken)
-	if testClient.serverToken != testServerToken {
+	t.Parallel()
+
+	client := NewClient(testServerToken)
+	if client.serverToken != testServerToken {
 		t.Fatal(fmt.Sprintf(
-			"Client.serverToken %s does not match %s", testClient.serverToken, testServerToken,
+			"Client.serverToken %s does not match %s", client.serverToken, testServerToken,
 		))
 	}
+	if client.apiHost != UberAPIHost || client.authHost != AuthHost || client.sandbox {
+		t.Fatalf("unexpected defaults %s %s %v", client.apiHost, client.authHost, client.sandbox)
+	}
+
+	hc := new(http.Client)
+	client = NewClient(testServerToken,
+		WithAPIHost("http://api"), WithAuthHost("http://auth"), WithTimeout(time.Second),
+		WithHTTPClient(hc), WithUserAgent("go-uber-test"), WithSandboxHost("http://sandbox"),
+	)
+	if client.apiHost != "http://api" || client.authHost != "http://auth" ||
+		client.userAgent != "go-uber-test" {
+		t.Fatalf("options were not applied: %+v", client)
+	}
+	if client.httpClient.Timeout != time.Second || hc.Timeout != 0 {
+		t.Fatal("the timeout should apply to a copy of the http.Client")
+	}
+	if client.host(RequestEndpoint+"/852b8fdd") != "http://sandbox" ||
+		client.host(ProductEndpoint) != "http://api" {
+		t.Fatal("only ride requests should go to the sandbox")
+	}
 }
 
-// TODO(r-medina): test `OAuth`
+func TestUserAgent(t *testing.T) {
+	t.Parallel()
 
-// TODO(r-medina): test `SetAccessToken`
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			userAgent = req.UserAgent()
+			getProductsHandler(rw, req)
+		},
+	))
+	defer server.Close()
+
+	client := NewClient(testServerToken, WithAPIHost(server.URL), WithUserAgent("go-uber-test"))
+	if _, err := client.GetProducts(123.0, 456.0); err != nil {
+		t.Fatal(err)
+	}
+	if userAgent != "go-uber-test" {
+		t.Fatalf("expected user agent go-uber-test, got %q", userAgent)
+	}
+}
 
 func TestGetProducts(t *testing.T) {
+	t.Parallel()
+
 	server := httptest.NewServer(http.HandlerFunc(getProductsHandler))
 	defer server.Close()
-	UberAPIHost = server.URL
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
 
-	_, err := testClient.GetProducts(123.0, 456.0)
+	_, err := client.GetProducts(123.0, 456.0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -111,11 +160,13 @@ func getProductsHandler(rw http.ResponseWriter, req *http.Request) {
 }
 
 func TestGetPrices(t *testing.T) {
+	t.Parallel()
+
 	server := httptest.NewServer(http.HandlerFunc(getPricesHandler))
 	defer server.Close()
-	UberAPIHost = server.URL
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
 
-	_, err := testClient.GetPrices(123.0, 456.0, 234.0, 567.0)
+	_, err := client.GetPrices(123.0, 456.0, 234.0, 567.0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -127,11 +178,13 @@ func getPricesHandler(rw http.ResponseWriter, req *http.Request) {
 }
 
 func TestGetTimes(t *testing.T) {
+	t.Parallel()
+
 	server := httptest.NewServer(http.HandlerFunc(getTimesHandler))
 	defer server.Close()
-	UberAPIHost = server.URL
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
 
-	_, err := testClient.GetTimes(123.0, 456.0, "" /* uuid */, "" /* productId */)
+	_, err := client.GetTimes(123.0, 456.0, "" /* uuid */, "" /* productId */)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -143,14 +196,66 @@ func getTimesHandler(rw http.ResponseWriter, req *http.Request) {
 }
 
 func TestGetUserActivity(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(getUserActivityHandler))
+	t.Parallel()
+
+	var path, query string
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			path, query = req.URL.Path, req.URL.RawQuery
+			getUserActivityHandler(rw, req)
+		},
+	))
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL+"/"+Version))
+
+	userActivity, err := client.GetUserActivity(0 /* offset */, 2 /* count */)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/v1/history" || query != "limit=2&offset=0" {
+		t.Fatalf("expected /v1/history?limit=2&offset=0, got %s?%s", path, query)
+	}
+	if !reflect.DeepEqual(userActivity.History, testUserActivity.History) {
+		t.Fatalf("expected %+v, got %+v", testUserActivity.History, userActivity.History)
+	}
+}
+
+func TestGetUserActivityV12(t *testing.T) {
+	t.Parallel()
+
+	var path, query string
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			path, query = req.URL.Path, req.URL.RawQuery
+			rw.Write([]byte(`{"offset": 10, "limit": 1, "count": 11, "history": [{
+"status": "completed", "distance": 1.64691465, "product_id": "a1111c8c",
+"start_time": 1428876188, "start_city": {"latitude": 37.7749295,
+"display_name": "San Francisco", "longitude": -122.4194155}, "end_time": 1428876927,
+"request_id": "37d57a99-2647-4114-9dd2-c43bccf4c30b", "request_time": 1428876165}]}`))
+		},
+	))
 	defer server.Close()
-	UberAPIHost = server.URL
+	client := NewClient(testServerToken, WithAPIHost(server.URL+"/"+Version))
 
-	_, err := testClient.GetUserActivity(0 /* offset */, 2 /* count */)
+	userActivity, err := client.GetUserActivityVersion(context.Background(), HistoryV12, 10, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	if path != "/v1.2/history" || query != "limit=1&offset=10" {
+		t.Fatalf("expected /v1.2/history?limit=1&offset=10, got %s?%s", path, query)
+	}
+	if userActivity.Version != HistoryV12 || userActivity.Count != 11 {
+		t.Fatalf("unexpected activity %+v", userActivity)
+	}
+
+	trip := userActivity.History[0]
+	expectedCity := &City{DisplayName: "San Francisco", Latitude: 37.7749295, Longitude: -122.4194155}
+	if trip.RequestID != "37d57a99-2647-4114-9dd2-c43bccf4c30b" ||
+		!reflect.DeepEqual(trip.StartCity, expectedCity) || trip.Distance != 1.64691465 {
+		t.Fatalf("unexpected trip %+v", trip)
+	}
 }
 
 func getUserActivityHandler(rw http.ResponseWriter, req *http.Request) {
@@ -159,11 +264,13 @@ func getUserActivityHandler(rw http.ResponseWriter, req *http.Request) {
 }
 
 func TestGetUserProfile(t *testing.T) {
+	t.Parallel()
+
 	server := httptest.NewServer(http.HandlerFunc(getUserProfileHandler))
 	defer server.Close()
-	UberAPIHost = server.URL
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
 
-	_, err := testClient.GetUserProfile()
+	_, err := client.GetUserProfile()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -174,14 +281,347 @@ func getUserProfileHandler(rw http.ResponseWriter, req *http.Request) {
 	rw.Write(body)
 }
 
+func TestPostRequest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		method, contentType string
+		body                map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			method = req.Method
+			contentType = req.Header.Get("Content-Type")
+			json.NewDecoder(req.Body).Decode(&body)
+			rw.Write([]byte(`{"request_id": "852b8fdd", "status": "processing", "eta": 5}`))
+		},
+	))
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
+
+	request, err := client.PostRequest("a1111c8c", 37.775, -122.417, 37.786, -122.402, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if method != "POST" {
+		t.Fatalf("expected POST, got %s", method)
+	}
+	if contentType != "application/json" {
+		t.Fatalf("expected a JSON content type, got %q", contentType)
+	}
+	expected := map[string]interface{}{
+		"product_id":      "a1111c8c",
+		"start_latitude":  37.775,
+		"start_longitude": -122.417,
+		"end_latitude":    37.786,
+		"end_longitude":   -122.402,
+	}
+	if !reflect.DeepEqual(body, expected) {
+		t.Fatalf("expected body %v, got %v", expected, body)
+	}
+	if request.RequestID != "852b8fdd" || request.ETADuration.Duration != 5*time.Minute ||
+		request.ETA != 5 {
+		t.Fatalf("unexpected request %+v", request)
+	}
+}
+
+func TestEstimateRequest(t *testing.T) {
+	t.Parallel()
+
+	var (
+		paths  []string
+		bodies []map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			var body map[string]interface{}
+			json.NewDecoder(req.Body).Decode(&body)
+			paths = append(paths, req.Method+" "+req.URL.Path)
+			bodies = append(bodies, body)
+
+			if req.URL.Path == "/v1.2/requests/estimate" {
+				rw.Write([]byte(`{"fare": {"value": 5.73, "fare_id": "d30e732b",
+"expires_at": 1476953293, "display": "$5.73", "currency_code": "USD"},
+"trip": {"distance_unit": "mile", "duration_estimate": 540, "distance_estimate": 2.39},
+"pickup_estimate": 2}`))
+				return
+			}
+			rw.Write([]byte(`{"request_id": "852b8fdd", "status": "processing"}`))
+		},
+	))
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL+"/"+Version))
+
+	spec := RideSpec{
+		ProductID: "a1111c8c",
+		Start:     At(37.775, -122.417),
+		End:       At(37.786, -122.402),
+	}
+	estimate, err := client.EstimateRequest(context.Background(), spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estimate.Fare.Value != 5.73 || estimate.Fare.FareID != "d30e732b" ||
+		estimate.Fare.Display != "$5.73" || estimate.Fare.CurrencyCode != "USD" ||
+		estimate.Fare.ExpiresAt.Unix() != 1476953293 {
+		t.Fatalf("unexpected fare %+v", estimate.Fare)
+	}
+	if estimate.PickupEstimate.Duration != 2*time.Minute ||
+		estimate.Trip.DurationEstimate.Duration != 9*time.Minute ||
+		estimate.Trip.DistanceEstimate != 2.39 || estimate.Trip.DistanceUnit != "mile" {
+		t.Fatalf("unexpected estimate %+v", estimate)
+	}
+
+	// the ride is booked at the estimated fare
+	spec.FareID = estimate.Fare.FareID
+	if _, err := client.RequestRide(context.Background(), spec); err != nil {
+		t.Fatal(err)
+	}
+	spec.FareID = ""
+	if _, err := client.RequestRide(context.Background(), spec); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"POST /v1.2/requests/estimate", "POST /v1.2/requests", "POST /v1/requests",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected %v, got %v", expected, paths)
+	}
+	if bodies[1]["fare_id"] != "d30e732b" || bodies[1]["product_id"] != "a1111c8c" {
+		t.Fatalf("expected the ride to be booked with the fare, got %v", bodies[1])
+	}
+	if _, ok := bodies[2]["fare_id"]; ok {
+		t.Fatalf("expected no fare, got %v", bodies[2])
+	}
+}
+
+func TestGetRequest(t *testing.T) {
+	t.Parallel()
+
+	var method, path string
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			method, path = req.Method, req.URL.Path
+			rw.Write([]byte(`{"request_id": "852b8fdd", "status": "accepted"}`))
+		},
+	))
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
+
+	request, err := client.GetRequest("852b8fdd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if method != "GET" || path != "/requests/852b8fdd" {
+		t.Fatalf("expected GET /requests/852b8fdd, got %s %s", method, path)
+	}
+	if request.Status != StatusAccepted {
+		t.Fatalf("expected status %s, got %s", StatusAccepted, request.Status)
+	}
+}
+
+func TestDeleteRequest(t *testing.T) {
+	t.Parallel()
+
+	var method, path string
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			method, path = req.Method, req.URL.Path
+			rw.WriteHeader(http.StatusNoContent)
+		},
+	))
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
+
+	if err := client.DeleteRequest("852b8fdd"); err != nil {
+		t.Fatal(err)
+	}
+
+	if method != "DELETE" || path != "/requests/852b8fdd" {
+		t.Fatalf("expected DELETE /requests/852b8fdd, got %s %s", method, path)
+	}
+}
+
+func TestHTTPReqDoMethods(t *testing.T) {
+	t.Parallel()
+
+	var (
+		method, query, contentType string
+		body                       []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			method, query = req.Method, req.URL.RawQuery
+			contentType = req.Header.Get("Content-Type")
+			body, _ = io.ReadAll(req.Body)
+			rw.Write([]byte("{}"))
+		},
+	))
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
+
+	payload := productsReq{latitude: 10, longitude: 20}
+	for _, test := range []struct {
+		method string
+		query  string
+		body   string
+	}{
+		{method: "GET", query: "latitude=10&longitude=20"},
+		{method: "DELETE", query: "latitude=10&longitude=20"},
+		{method: "POST", body: `{"latitude":10,"longitude":20}`},
+		{method: "PUT", body: `{"latitude":10,"longitude":20}`},
+		{method: "PATCH", body: `{"latitude":10,"longitude":20}`},
+	} {
+		out := new(map[string]interface{})
+		err := client.httpReqDo(context.Background(), test.method, "", payload, false, out)
+		if err != nil {
+			t.Fatalf("%s: %v", test.method, err)
+		}
+
+		if method != test.method {
+			t.Fatalf("expected method %s, got %s", test.method, method)
+		}
+		if query != test.query {
+			t.Fatalf("%s: expected query %q, got %q", test.method, test.query, query)
+		}
+		if string(body) != test.body {
+			t.Fatalf("%s: expected body %q, got %q", test.method, test.body, body)
+		}
+		if test.body != "" && contentType != "application/json" {
+			t.Fatalf("%s: expected a JSON content type, got %q", test.method, contentType)
+		}
+	}
+}
+
+// newTestAuthServer returns a server that hands out "fresh_token" in exchange for
+// "refresh_token" and counts how many times it has done so.
+func newTestAuthServer(t *testing.T, refreshes *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			req.ParseForm()
+			if req.URL.Path != "/"+AccessTokenEndpoint ||
+				req.PostForm.Get("grant_type") != "refresh_token" ||
+				req.PostForm.Get("refresh_token") != "refresh_token" ||
+				req.PostForm.Get("client_id") != "client_id" {
+				t.Errorf("unexpected refresh request %s %v", req.URL.Path, req.PostForm)
+			}
+			atomic.AddInt32(refreshes, 1)
+
+			rw.Write([]byte(`{"access_token": "fresh_token", "token_type": "Bearer",
+"expires_in": 2592000, "refresh_token": "refresh_token", "scope": "profile"}`))
+		},
+	))
+}
+
+func newTestRefreshClient(issued time.Time, opts ...ClientOption) *Client {
+	client := NewClient(testServerToken, opts...)
+	client.auth = &auth{
+		clientID:     "client_id",
+		clientSecret: "client_secret",
+		redirectURI:  "http://localhost" + Port,
+	}
+	client.setAccess(&access{
+		Token:        "stale_token",
+		TokenType:    "Bearer",
+		ExpiresIn:    2592000,
+		RefreshToken: "refresh_token",
+		IssuedAt:     issued,
+	})
+
+	return client
+}
+
+func TestRefreshOnUnauthorized(t *testing.T) {
+	t.Parallel()
+
+	var refreshes int32
+	authServer := newTestAuthServer(t, &refreshes)
+	defer authServer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "Bearer fresh_token" {
+				rw.WriteHeader(http.StatusUnauthorized)
+				rw.Write([]byte(`{"message": "Invalid OAuth 2.0 credentials provided.",
+"code": "unauthorized"}`))
+				return
+			}
+			getUserProfileHandler(rw, req)
+		},
+	))
+	defer server.Close()
+	client := newTestRefreshClient(
+		time.Now(), WithAPIHost(server.URL), WithAuthHost(authServer.URL),
+	)
+	if _, err := client.GetUserProfile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if refreshes != 1 {
+		t.Fatalf("expected 1 refresh, got %d", refreshes)
+	}
+	if client.currentAccess().Token != "fresh_token" {
+		t.Fatalf("expected the fresh token, got %s", client.currentAccess().Token)
+	}
+}
+
+func TestRefreshBeforeExpiry(t *testing.T) {
+	t.Parallel()
+
+	var refreshes int32
+	authServer := newTestAuthServer(t, &refreshes)
+	defer authServer.Close()
+
+	var unauthorized int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "Bearer fresh_token" {
+				atomic.AddInt32(&unauthorized, 1)
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			getUserProfileHandler(rw, req)
+		},
+	))
+	defer server.Close()
+	// issued 30 days ago, so it has just expired
+	client := newTestRefreshClient(
+		time.Now().Add(-2592000*time.Second), WithAPIHost(server.URL), WithAuthHost(authServer.URL),
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetUserProfile(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if refreshes != 1 {
+		t.Fatalf("expected 1 refresh, got %d", refreshes)
+	}
+	if unauthorized != 0 {
+		t.Fatalf("expected no request with the stale token, got %d", unauthorized)
+	}
+}
+
 // TODO(r-medina): do this
 func TestGet(t *testing.T) {
+	t.Parallel()
+
 	server := httptest.NewServer(http.HandlerFunc(getHandler))
 	defer server.Close()
-	UberAPIHost = server.URL
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
 
 	out := new(map[string]interface{})
-	if err := testClient.get("", struct{}{}, false, out); err != nil {
+	if err := client.get(context.Background(), "", struct{}{}, false, out); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -190,6 +630,35 @@ func getHandler(rw http.ResponseWriter, req *http.Request) {
 	rw.Write([]byte("{\"a\": \"b\"}"))
 }
 
+func TestContextDeadline(t *testing.T) {
+	t.Parallel()
+
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			// hangs until the client goes away
+			<-req.Context().Done()
+			close(done)
+		},
+	))
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.GetProductsContext(ctx, 123.0, 456.0)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("request was not cancelled on the server")
+	}
+}
+
 // TODO(r-medina): fix this test
 // func TestSendRequestWithAuthorization(t *testing.T) {
 // 	server := httptest.NewServer(http.HandlerFunc(sendRequestWithAuthorizationHandler))
@@ -223,6 +692,9 @@ func getHandler(rw http.ResponseWriter, req *http.Request) {
 // }
 
 func TestGenerateRequestURL(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(testServerToken)
 	lat := 10.0
 	lon := 20.0
 
@@ -232,7 +704,7 @@ func TestGenerateRequestURL(t *testing.T) {
 		longitude: lon,
 	}
 
-	url, err := testClient.generateRequestURL(UberAPIHost, PriceEndpoint, products)
+	url, err := client.generateRequestURL(UberAPIHost, PriceEndpoint, products)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -242,7 +714,7 @@ func TestGenerateRequestURL(t *testing.T) {
 	}
 
 	// Generate url without query parameters.
-	url, err = testClient.generateRequestURL(UberAPIHost, UserEndpoint, nil)
+	url, err = client.generateRequestURL(UberAPIHost, UserEndpoint, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -257,7 +729,7 @@ func TestGenerateRequestURL(t *testing.T) {
 		startLongitude: lon,
 	}
 
-	url, err = testClient.generateRequestURL(UberAPIHost, TimeEndpoint, times)
+	url, err = client.generateRequestURL(UberAPIHost, TimeEndpoint, times)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -269,3 +741,73 @@ func TestGenerateRequestURL(t *testing.T) {
 		t.Fatal(fmt.Sprintf("URL generation failed: Expected %s, got %s", expectedURL, url))
 	}
 }
+
+func TestTimeTypes(t *testing.T) {
+	t.Parallel()
+
+	trip := new(Trip)
+	err := json.Unmarshal([]byte(
+		`{"request_time": 1401884467, "start_time": 1401884646.5, "end_time": null}`,
+	), trip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !trip.RequestedAt.Equal(time.Date(2014, 6, 4, 12, 21, 7, 0, time.UTC)) ||
+		trip.StartedAt.Sub(trip.RequestedAt.Time) != 179500*time.Millisecond ||
+		!trip.EndedAt.IsZero() {
+		t.Fatalf("unexpected times %v %v %v", trip.RequestedAt, trip.StartedAt, trip.EndedAt)
+	}
+	if trip.RequestTime != 1401884467 || trip.StartTime != 1401884646 || trip.EndTime != 0 {
+		t.Fatalf("unexpected deprecated times %d %d %d",
+			trip.RequestTime, trip.StartTime, trip.EndTime)
+	}
+
+	estimate := new(Time)
+	if err := json.Unmarshal([]byte(`{"estimate": 410}`), estimate); err != nil {
+		t.Fatal(err)
+	}
+	if estimate.EstimateDuration.Duration != 6*time.Minute+50*time.Second ||
+		estimate.Estimate != 410 {
+		t.Fatalf("unexpected estimate %+v", estimate)
+	}
+
+	request := new(Request)
+	if err := json.Unmarshal([]byte(`{"eta": 2.5}`), request); err != nil {
+		t.Fatal(err)
+	}
+	if request.ETADuration.Duration != 150*time.Second || request.ETA != 2 {
+		t.Fatalf("unexpected eta %+v", request)
+	}
+
+	// the api's formats are marshalled back as they came
+	for v, expected := range map[interface{}]string{
+		timestamp(1401884467):      "1401884467",
+		trip.StartedAt:             "1401884646.5",
+		Timestamp{}:                "0",
+		Seconds{410 * time.Second}: "410",
+		Minutes{150 * time.Second}: "2.5",
+		Minutes{3 * time.Minute}:   "3",
+	} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected {
+			t.Fatalf("expected %T to marshal to %s, got %s", v, expected, data)
+		}
+	}
+
+	// only setting the deprecated fields still works
+	data, err := json.Marshal(Trip{RequestTime: 1401884467})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trip = new(Trip)
+	if err := json.Unmarshal(data, trip); err != nil {
+		t.Fatal(err)
+	}
+	if trip.RequestTime != 1401884467 || trip.RequestedAt.Unix() != 1401884467 {
+		t.Fatalf("expected the request time to survive a round trip, got %s", data)
+	}
+}
@@ -0,0 +1,117 @@
+package uber
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Backoff is how long `WaitForStatus` waits between polls: `Initial` at first, then
+// `Multiplier` times longer after every poll, up to `Max`. Each wait is shortened by a
+// random fraction of itself, up to `Jitter`, so that clients don't poll in lockstep.
+type Backoff struct {
+	// Taken as `DefaultBackoff.Initial` if not positive
+	// eg: time.Second
+	Initial time.Duration
+
+	// eg: 30 * time.Second
+	Max time.Duration
+
+	// Less than 1 is taken as 1, ie: no backoff
+	// eg: 1.5
+	Multiplier float64
+
+	// Between 0 (none) and 1
+	// eg: 0.2
+	Jitter float64
+}
+
+// DefaultBackoff is the `Backoff` of new clients (see `WithBackoff`).
+var DefaultBackoff = Backoff{
+	Initial:    time.Second,
+	Max:        30 * time.Second,
+	Multiplier: 1.5,
+	Jitter:     0.2,
+}
+
+// delay returns how long to wait before poll number `attempt`, counting from 0.
+func (b Backoff) delay(attempt int) time.Duration {
+	d := float64(b.Initial)
+	for i := 0; i < attempt && b.Multiplier > 1; i++ {
+		d *= b.Multiplier
+		if b.Max > 0 && d >= float64(b.Max) {
+			break
+		}
+	}
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+
+	if b.Jitter > 0 {
+		d -= d * min(b.Jitter, 1) * rand.Float64()
+	}
+
+	return time.Duration(d)
+}
+
+// RideFailedError is returned by `WaitForStatus` when a ride request ends without
+// reaching the status that was waited for, eg: with `StatusNoDrivers`,
+// `StatusDriverCanceled` or `StatusRiderCanceled`.
+type RideFailedError struct {
+	// The request, as of the poll that found it failed
+	Request *Request
+}
+
+// Error implements the `error` interface for `RideFailedError`.
+func (err *RideFailedError) Error() string {
+	return fmt.Sprintf(
+		"uber: ride request %s ended with status %q", err.Request.RequestID, err.Request.Status,
+	)
+}
+
+// WaitForStatus polls the ride request `requestID` until it reaches one of the `targets`
+// (by default `StatusAccepted`), and returns it. A target counts as reached if the
+// request has gone past it, eg: waiting for `StatusAccepted` returns as soon as the
+// driver is `StatusArriving`, even if no poll saw the request accepted. A request that
+// was canceled or found no driver hasn't gone past any target, unless it is one.
+//
+// If the request ends before then, eg: because no drivers were available, it is
+// returned along with a `*RideFailedError`. Errors from the api, and `ctx` being done,
+// stop the wait. The time between polls is set with `WithBackoff`.
+func (c *Client) WaitForStatus(
+	ctx context.Context, requestID string, targets ...RideStatus,
+) (*Request, error) {
+	if len(targets) == 0 {
+		targets = []RideStatus{StatusAccepted}
+	}
+
+	for attempt := 0; ; attempt++ {
+		request, err := c.GetRequestContext(ctx, requestID)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// going past a target counts, but not by failing, eg: being canceled
+		passed := !request.Status.IsTerminal() || request.Status == StatusCompleted
+		for _, target := range targets {
+			if request.Status == target || passed && target.CanTransitionTo(request.Status) {
+				return request, nil
+			}
+		}
+		if request.Status.IsTerminal() {
+			return request, &RideFailedError{Request: request}
+		}
+
+		timer := time.NewTimer(c.backoff.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
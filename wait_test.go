@@ -0,0 +1,156 @@
+package uber
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testBackoff keeps the tests fast.
+var testBackoff = Backoff{Initial: time.Millisecond, Max: 4 * time.Millisecond, Multiplier: 2}
+
+// newStatusServer answers the n-th poll of a request with the n-th of `statuses`, and
+// keeps answering with the last one.
+func newStatusServer(polls *int32, statuses ...RideStatus) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			n := int(atomic.AddInt32(polls, 1)) - 1
+			if n >= len(statuses) {
+				n = len(statuses) - 1
+			}
+			fmt.Fprintf(rw, `{"request_id": "852b8fdd", "status": %q}`, statuses[n])
+		},
+	))
+}
+
+func TestWaitForStatus(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		statuses []RideStatus
+		targets  []RideStatus
+		polls    int32
+		status   RideStatus
+		failed   bool
+	}{
+		{
+			statuses: []RideStatus{StatusProcessing, StatusProcessing, StatusAccepted},
+			polls:    3, status: StatusAccepted,
+		},
+		{
+			// the request went past the target between two polls
+			statuses: []RideStatus{StatusProcessing, StatusArriving},
+			polls:    2, status: StatusArriving,
+		},
+		{
+			statuses: []RideStatus{StatusProcessing, StatusAccepted, StatusInProgress},
+			targets:  []RideStatus{StatusInProgress, StatusCompleted},
+			polls:    3, status: StatusInProgress,
+		},
+		{
+			statuses: []RideStatus{StatusProcessing, StatusNoDrivers},
+			polls:    2, status: StatusNoDrivers, failed: true,
+		},
+		{
+			// canceled rides don't count as past the default target
+			statuses: []RideStatus{StatusProcessing, StatusRiderCanceled},
+			polls:    2, status: StatusRiderCanceled, failed: true,
+		},
+		{
+			// the driver accepted, and canceled, between two polls
+			statuses: []RideStatus{StatusProcessing, StatusDriverCanceled},
+			polls:    2, status: StatusDriverCanceled, failed: true,
+		},
+		{
+			statuses: []RideStatus{StatusAccepted, StatusCompleted},
+			targets:  []RideStatus{StatusInProgress},
+			polls:    2, status: StatusCompleted,
+		},
+		{
+			statuses: []RideStatus{StatusAccepted, StatusDriverCanceled},
+			targets:  []RideStatus{StatusInProgress},
+			polls:    2, status: StatusDriverCanceled, failed: true,
+		},
+		{
+			statuses: []RideStatus{StatusAccepted, StatusRiderCanceled},
+			targets:  []RideStatus{StatusRiderCanceled},
+			polls:    2, status: StatusRiderCanceled,
+		},
+	} {
+		var polls int32
+		server := newStatusServer(&polls, test.statuses...)
+		client := NewClient(testServerToken, WithAPIHost(server.URL), WithBackoff(testBackoff))
+
+		request, err := client.WaitForStatus(context.Background(), "852b8fdd", test.targets...)
+		server.Close()
+
+		if test.failed {
+			ferr, ok := err.(*RideFailedError)
+			if !ok || ferr.Request.Status != test.status {
+				t.Fatalf("expected a RideFailedError with %s, got %v", test.status, err)
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if n := atomic.LoadInt32(&polls); request == nil || request.Status != test.status ||
+			n != test.polls {
+			t.Fatalf("expected %s after %d polls, got %+v after %d", test.status, test.polls,
+				request, n)
+		}
+	}
+}
+
+func TestWaitForStatusContext(t *testing.T) {
+	t.Parallel()
+
+	var polls int32
+	server := newStatusServer(&polls, StatusProcessing)
+	defer server.Close()
+	client := NewClient(testServerToken, WithAPIHost(server.URL), WithBackoff(testBackoff))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := client.WaitForStatus(ctx, "852b8fdd"); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if n := atomic.LoadInt32(&polls); time.Since(start) > time.Second || n < 2 {
+		t.Fatalf("expected a few polls until the deadline, got %d in %s", n, time.Since(start))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	b := Backoff{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2}
+	for attempt, expected := range []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second,
+	} {
+		if d := b.delay(attempt); d != expected {
+			t.Fatalf("expected delay %d to be %s, got %s", attempt, expected, d)
+		}
+	}
+
+	b.Jitter = 0.5
+	for attempt := 0; attempt < 100; attempt++ {
+		if d := b.delay(3); d < 2500*time.Millisecond || d > 5*time.Second {
+			t.Fatalf("expected a jittered delay between 2.5s and 5s, got %s", d)
+		}
+	}
+}
+
+func TestWithBackoff(t *testing.T) {
+	t.Parallel()
+
+	for _, d := range []time.Duration{0, -time.Second} {
+		c := NewClient(testServerToken, WithBackoff(Backoff{Initial: d, Multiplier: 2}))
+		if c.backoff.Initial != DefaultBackoff.Initial || c.backoff.Multiplier != 2 {
+			t.Fatalf("expected %s to be taken as the default, got %+v", d, c.backoff)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package uber
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// DefaultPollInterval is how often `WatchRequest` polls, unless the client was created
+// with `WithPollInterval`.
+const DefaultPollInterval = 5 * time.Second
+
+// RideEvent is a change to a ride request seen by `WatchRequest`. It is one of
+// `*StatusChanged`, `*ETAChanged`, `*DriverChanged`, `*VehicleChanged` and
+// `*LocationChanged`.
+type RideEvent interface {
+	rideEvent()
+}
+
+// StatusChanged is sent when the status of a request changes.
+type StatusChanged struct {
+	Old, New RideStatus
+
+	// The request as of the poll that saw the change
+	Request *Request
+}
+
+// ETAChanged is sent when the ETA of a request changes.
+type ETAChanged struct {
+	Old, New time.Duration
+
+	// The request as of the poll that saw the change
+	Request *Request
+}
+
+// DriverChanged is sent when a driver is assigned to a request, or when their
+// information changes. `Old` is the zero `Driver` if no driver was assigned.
+type DriverChanged struct {
+	Old, New Driver
+
+	// The request as of the poll that saw the change
+	Request *Request
+}
+
+// VehicleChanged is sent when a vehicle is assigned to a request, or when its
+// information changes. `Old` is the zero `Vehicle` if no vehicle was assigned.
+type VehicleChanged struct {
+	Old, New Vehicle
+
+	// The request as of the poll that saw the change
+	Request *Request
+}
+
+// LocationChanged is sent when the location of a request's vehicle changes.
+type LocationChanged struct {
+	Old, New Location
+
+	// The request as of the poll that saw the change
+	Request *Request
+}
+
+func (*StatusChanged) rideEvent()   {}
+func (*ETAChanged) rideEvent()      {}
+func (*DriverChanged) rideEvent()   {}
+func (*VehicleChanged) rideEvent()  {}
+func (*LocationChanged) rideEvent() {}
+
+// WatchRequest polls the ride request `requestID` and sends what changes between polls
+// on the returned events channel. The first poll is compared to an empty `Request`, so
+// it reports the request's status and whatever it already has, eg: a driver.
+//
+// Errors are sent on the returned errors channel, and the watch carries on: it stops,
+// closing both channels, when the request reaches a terminal status or `ctx` is done.
+// It also stops after an error that polling again won't clear, ie: an
+// `*ErrMissingScope` or a 4xx other than 429, eg: the request doesn't exist. That error
+// is always the last one on the errors channel.
+// A status the request can't move to (see `CheckTransition`) is reported as a
+// `*TransitionError`, after its `StatusChanged`.
+//
+// The events channel must be received from until it is closed, or `ctx` cancelled. The
+// errors channel holds one error: while it is unread, later errors are dropped rather
+// than block the watch, so it is fine to range over the events and only then read the
+// errors.
+//
+// The time between polls is set with `WithPollInterval`.
+func (c *Client) WatchRequest(
+	ctx context.Context, requestID string,
+) (<-chan RideEvent, <-chan error) {
+	events := make(chan RideEvent)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+		defer close(errs)
+
+		ticker := time.NewTicker(c.pollInterval)
+		defer ticker.Stop()
+
+		old := new(Request)
+		for {
+			request, err := c.GetRequestContext(ctx, requestID)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil && permanent(err):
+				reportLast(errs, err)
+				return
+			case err != nil:
+				report(errs, err)
+			default:
+				for _, event := range rideEvents(old, request) {
+					if !send(ctx, events, event) {
+						return
+					}
+				}
+				if request.Status != old.Status {
+					if err := CheckTransition(old.Status, request.Status); err != nil {
+						report(errs, err)
+					}
+				}
+				if request.Status.IsTerminal() {
+					return
+				}
+
+				old = request
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, errs
+}
+
+// rideEvents returns the events for the changes from `old` to `request`.
+func rideEvents(old, request *Request) []RideEvent {
+	var events []RideEvent
+
+	if request.Status != old.Status {
+		events = append(events, &StatusChanged{old.Status, request.Status, request})
+	}
+	if request.Driver != old.Driver {
+		events = append(events, &DriverChanged{old.Driver, request.Driver, request})
+	}
+	if request.Vehicle != old.Vehicle {
+		events = append(events, &VehicleChanged{old.Vehicle, request.Vehicle, request})
+	}
+	if request.ETADuration != old.ETADuration {
+		events = append(events, &ETAChanged{
+			old.ETADuration.Duration, request.ETADuration.Duration, request,
+		})
+	}
+	if request.Location != old.Location {
+		events = append(events, &LocationChanged{old.Location, request.Location, request})
+	}
+
+	return events
+}
+
+// report sends `err` on `errs`, unless an error is already waiting there to be read.
+func report(errs chan<- error, err error) {
+	select {
+	case errs <- err:
+	default:
+	}
+}
+
+// reportLast sends `err` on `errs`, in place of any error waiting there to be read.
+func reportLast(errs chan error, err error) {
+	for {
+		select {
+		case errs <- err:
+			return
+		default:
+		}
+
+		select {
+		case <-errs:
+		default:
+		}
+	}
+}
+
+// permanent reports whether `err` would be returned again by the same call, whatever
+// the time.
+func permanent(err error) bool {
+	var scopeErr *ErrMissingScope
+	if errors.As(err, &scopeErr) {
+		return true
+	}
+
+	code := statusCode(err)
+	return code >= 400 && code < 500 && code != http.StatusTooManyRequests
+}
+
+// send sends `v` on `ch`, unless `ctx` is done first.
+func send[T any](ctx context.Context, ch chan<- T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
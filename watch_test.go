@@ -0,0 +1,258 @@
+package uber
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newPollServer answers the n-th poll with the n-th of `bodies`, and keeps answering
+// with the last one. An empty body is an error.
+func newPollServer(bodies ...string) *httptest.Server {
+	var polls int32
+	return httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			n := int(atomic.AddInt32(&polls, 1)) - 1
+			if n >= len(bodies) {
+				n = len(bodies) - 1
+			}
+			if bodies[n] == "" {
+				rw.WriteHeader(http.StatusInternalServerError)
+				rw.Write([]byte(`{"message": "oops"}`))
+				return
+			}
+			rw.Write([]byte(bodies[n]))
+		},
+	))
+}
+
+func TestWatchRequest(t *testing.T) {
+	t.Parallel()
+
+	server := newPollServer(
+		`{"status": "processing"}`,
+		`{"status": "processing"}`,
+		`{"status": "accepted", "eta": 5, "driver": {"name": "Bob"},
+"vehicle": {"make": "Bugatti", "model": "Veyron"},
+"location": {"latitude": 37.776, "longitude": -122.417}}`,
+		``,
+		`{"status": "arriving", "eta": 1, "driver": {"name": "Bob"},
+"vehicle": {"make": "Bugatti", "model": "Veyron"},
+"location": {"latitude": 37.775, "longitude": -122.418}}`,
+		`{"status": "completed", "driver": {"name": "Bob"},
+"vehicle": {"make": "Bugatti", "model": "Veyron"},
+"location": {"latitude": 37.775, "longitude": -122.418}}`,
+	)
+	defer server.Close()
+	client := NewClient(
+		testServerToken, WithAPIHost(server.URL), WithPollInterval(time.Millisecond),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, errs := client.WatchRequest(ctx, "852b8fdd")
+
+	var got []RideEvent
+	var gotErrs []error
+	for events != nil || errs != nil {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			got = append(got, event)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			gotErrs = append(gotErrs, err)
+		}
+	}
+
+	if len(got) != 11 {
+		t.Fatalf("expected 11 events, got %d: %+v", len(got), got)
+	}
+
+	status, ok := got[0].(*StatusChanged)
+	if !ok || status.Old != "" || status.New != StatusProcessing {
+		t.Fatalf("expected processing first, got %+v", got[0])
+	}
+	if e, ok := got[1].(*StatusChanged); !ok || e.Old != StatusProcessing || e.New != StatusAccepted {
+		t.Fatalf("expected accepted, got %+v", got[1])
+	}
+	if e, ok := got[2].(*DriverChanged); !ok || e.Old != (Driver{}) || e.New.Name != "Bob" {
+		t.Fatalf("expected a driver, got %+v", got[2])
+	}
+	if e, ok := got[3].(*VehicleChanged); !ok || e.New.Model != "Veyron" {
+		t.Fatalf("expected a vehicle, got %+v", got[3])
+	}
+	if e, ok := got[4].(*ETAChanged); !ok || e.Old != 0 || e.New != 5*time.Minute {
+		t.Fatalf("expected an ETA, got %+v", got[4])
+	}
+	if e, ok := got[5].(*LocationChanged); !ok || e.New.Latitude != 37.776 {
+		t.Fatalf("expected a location, got %+v", got[5])
+	}
+	if e, ok := got[6].(*StatusChanged); !ok || e.New != StatusArriving {
+		t.Fatalf("expected arriving, got %+v", got[6])
+	}
+	if e, ok := got[7].(*ETAChanged); !ok || e.Old != 5*time.Minute || e.New != time.Minute {
+		t.Fatalf("expected a shorter ETA, got %+v", got[7])
+	}
+	if e, ok := got[8].(*LocationChanged); !ok || e.Old.Latitude != 37.776 ||
+		e.New.Latitude != 37.775 || e.Request.Status != StatusArriving {
+		t.Fatalf("expected the vehicle to move, got %+v", got[8])
+	}
+
+	// arriving -> completed skips in_progress, which isn't an error
+	if e, ok := got[9].(*StatusChanged); !ok || e.Old != StatusArriving || e.New != StatusCompleted {
+		t.Fatalf("expected completed, got %+v", got[9])
+	}
+	if e, ok := got[10].(*ETAChanged); !ok || e.New != 0 {
+		t.Fatalf("expected the ETA to go away, got %+v", got[10])
+	}
+
+	if len(gotErrs) != 1 {
+		t.Fatalf("expected the failed poll's error, got %v", gotErrs)
+	}
+	if _, ok := gotErrs[0].(uberError); !ok {
+		t.Fatalf("expected an uberError, got %v", gotErrs[0])
+	}
+}
+
+func TestWatchRequestTransition(t *testing.T) {
+	t.Parallel()
+
+	server := newPollServer(
+		`{"status": "in_progress"}`, `{"status": "accepted"}`, `{"status": "completed"}`,
+	)
+	defer server.Close()
+	client := NewClient(
+		testServerToken, WithAPIHost(server.URL), WithPollInterval(time.Millisecond),
+	)
+
+	events, errs := client.WatchRequest(context.Background(), "852b8fdd")
+	go func() {
+		for range events {
+		}
+	}()
+
+	var terr *TransitionError
+	for err := range errs {
+		terr, _ = err.(*TransitionError)
+	}
+	if terr == nil || terr.From != StatusInProgress || terr.To != StatusAccepted {
+		t.Fatalf("expected a TransitionError from in_progress to accepted, got %v", terr)
+	}
+}
+
+func TestWatchRequestContext(t *testing.T) {
+	t.Parallel()
+
+	server := newPollServer(`{"status": "processing"}`)
+	defer server.Close()
+	client := NewClient(
+		testServerToken, WithAPIHost(server.URL), WithPollInterval(time.Millisecond),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errs := client.WatchRequest(ctx, "852b8fdd")
+
+	if _, ok := (<-events).(*StatusChanged); !ok {
+		t.Fatal("expected a status")
+	}
+	cancel()
+
+	// both channels get closed
+	for range events {
+	}
+	for range errs {
+	}
+}
+
+func TestWatchRequestErrors(t *testing.T) {
+	t.Parallel()
+
+	// every poll fails until the request is over
+	server := newPollServer(``, ``, ``, `{"status": "completed"}`)
+	defer server.Close()
+	client := NewClient(
+		testServerToken, WithAPIHost(server.URL), WithPollInterval(time.Millisecond),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, errs := client.WatchRequest(ctx, "852b8fdd")
+
+	// the events can be read to the end before the errors
+	var got []RideEvent
+	for event := range events {
+		got = append(got, event)
+	}
+	if ctx.Err() != nil || len(got) != 1 {
+		t.Fatalf("expected the watch to end with completed, got %v", got)
+	}
+
+	var gotErrs []error
+	for err := range errs {
+		gotErrs = append(gotErrs, err)
+	}
+	if len(gotErrs) != 1 {
+		t.Fatalf("expected the first error only, got %v", gotErrs)
+	}
+}
+
+func TestWatchRequestPermanentError(t *testing.T) {
+	t.Parallel()
+
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(
+		func(rw http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&polls, 1)
+			rw.WriteHeader(http.StatusNotFound)
+			rw.Write([]byte(`{"message": "Request not found", "code": "not_found"}`))
+		},
+	))
+	defer server.Close()
+	client := NewClient(
+		testServerToken, WithAPIHost(server.URL), WithPollInterval(time.Millisecond),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, errs := client.WatchRequest(ctx, "852b8fdd")
+
+	// a request that doesn't exist never will, so the watch ends
+	for event := range events {
+		t.Fatalf("unexpected event %v", event)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("expected the watch to end by itself")
+	}
+
+	var gotErrs []error
+	for err := range errs {
+		gotErrs = append(gotErrs, err)
+	}
+	if len(gotErrs) != 1 || statusCode(gotErrs[0]) != http.StatusNotFound {
+		t.Fatalf("expected a 404, got %v", gotErrs)
+	}
+	if n := atomic.LoadInt32(&polls); n != 1 {
+		t.Fatalf("expected a single poll, got %d", n)
+	}
+}
+
+func TestWithPollInterval(t *testing.T) {
+	t.Parallel()
+
+	for _, d := range []time.Duration{0, -time.Second} {
+		c := NewClient(testServerToken, WithPollInterval(d))
+		if c.pollInterval != DefaultPollInterval {
+			t.Fatalf("expected %s to leave the default, got %s", d, c.pollInterval)
+		}
+	}
+}
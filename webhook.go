@@ -0,0 +1,295 @@
+package uber
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The types of the webhook events Uber sends.
+// https://developer.uber.com/docs/riders/guides/webhooks
+const (
+	// The status of a ride request changed.
+	EventStatusChanged WebhookEventType = "requests.status_changed"
+	// The receipt of a ride request is ready.
+	EventReceiptReady WebhookEventType = "requests.receipt_ready"
+)
+
+// WebhookSignatureHeader is the header that carries the signature of a webhook.
+const WebhookSignatureHeader = "X-Uber-Signature"
+
+// DefaultWebhookMaxAge is how old a webhook event can be before a `WebhookHandler`
+// rejects it, unless told otherwise with `WithWebhookMaxAge`.
+const DefaultWebhookMaxAge = 10 * time.Minute
+
+// webhookRetention is how long a `WebhookHandler` that accepts events of any age (see
+// `WithWebhookMaxAge`) remembers an event after receiving it, to drop its duplicates.
+const webhookRetention = time.Hour
+
+// maxWebhookSize is the largest webhook body a `WebhookHandler` reads.
+const maxWebhookSize = 1 << 20
+
+// Errors passed to the `WebhookHandler.OnError` callback when a webhook is rejected.
+var (
+	ErrWebhookSignature = errors.New("uber: invalid webhook signature")
+	ErrWebhookStale     = errors.New("uber: stale webhook event")
+	ErrWebhookDuplicate = errors.New("uber: duplicate webhook event")
+)
+
+// WebhookEventType is the type of a `WebhookEvent`, eg: `EventStatusChanged`.
+type WebhookEventType string
+
+// WebhookEvent is a notification Uber sends to your app's webhook URL.
+type WebhookEvent struct {
+	// Unique identifier of the event, the same across retries
+	// eg: "3a3f3da4-14ac-4056-bbf2-d0b9cdcb0777"
+	EventID string `json:"event_id"`
+
+	// When the event happened
+	// eg: 1427343990
+	EventTime Timestamp `json:"event_time"`
+
+	// eg: "requests.status_changed"
+	EventType WebhookEventType `json:"event_type"`
+
+	// Self explanatory (see `WebhookMeta`)
+	Meta WebhookMeta `json:"meta"`
+
+	// Where the resource the event is about can be retrieved
+	// eg: "https://api.uber.com/v1/requests/2a2f3da4-14ac-4056-bbf2-d0b9cdcb0777"
+	ResourceHref string `json:"resource_href"`
+}
+
+// WebhookMeta describes what a `WebhookEvent` is about.
+type WebhookMeta struct {
+	// The user the resource belongs to
+	// eg: "d13dff8b-1f16-4e40-9fa7-bf34fd7c7bb7"
+	UserID string `json:"user_id"`
+
+	// The ride request the event is about
+	// eg: "2a2f3da4-14ac-4056-bbf2-d0b9cdcb0777"
+	ResourceID string `json:"resource_id"`
+
+	// The status of the ride request, for `EventStatusChanged`
+	// eg: "accepted"
+	Status RideStatus `json:"status"`
+}
+
+// WebhookOption configures a `WebhookHandler`.
+type WebhookOption func(*WebhookHandler)
+
+// WithWebhookSecret makes the handler verify signatures with `secret` rather than the
+// client secret the client was given with `SetCredentials` or `OAuth`.
+func WithWebhookSecret(secret string) WebhookOption {
+	return func(h *WebhookHandler) {
+		h.secret = []byte(secret)
+	}
+}
+
+// WithWebhookMaxAge sets how old an event can be before it is rejected. With 0, events
+// of any age are accepted, and duplicates are only dropped for an hour after the first
+// one was received.
+func WithWebhookMaxAge(d time.Duration) WebhookOption {
+	return func(h *WebhookHandler) {
+		h.maxAge = d
+	}
+}
+
+// WebhookHandler is an `http.Handler` that receives Uber's webhooks. It checks their
+// signature, drops the stale and duplicate ones and calls the callbacks registered for
+// their type. Create it with `Client.WebhookHandler`.
+//
+// A webhook whose callback returns an error gets a 500, so that Uber sends it again,
+// and isn't counted as seen. Duplicates get a 200 since they have already been
+// handled; the other rejected webhooks get a 4xx.
+type WebhookHandler struct {
+	client *Client
+	secret []byte
+	maxAge time.Duration
+	now    func() time.Time
+
+	// the IDs of the events that have been handled, or are being, and when they can be
+	// forgotten
+	mu   sync.Mutex
+	seen map[string]time.Time
+
+	onStatusChanged func(context.Context, *WebhookEvent, *Request) error
+	onReceiptReady  func(context.Context, *WebhookEvent) error
+	onError         func(error)
+}
+
+// WebhookHandler returns a handler for the webhooks of the app the client is
+// authorized for. Their signatures are verified with the app's client secret.
+func (c *Client) WebhookHandler(opts ...WebhookOption) (*WebhookHandler, error) {
+	h := &WebhookHandler{
+		client: c,
+		maxAge: DefaultWebhookMaxAge,
+		now:    time.Now,
+		seen:   make(map[string]time.Time),
+	}
+	if c.auth != nil {
+		h.secret = []byte(c.auth.clientSecret)
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	if len(h.secret) == 0 {
+		return nil, errors.New("uber: a client secret is needed to verify webhooks")
+	}
+
+	return h, nil
+}
+
+// OnStatusChanged registers the callback for `EventStatusChanged`. The handler gets the
+// ride request the event is about with `Client.GetRequest`, so the client must be
+// authorized for the user it belongs to.
+func (h *WebhookHandler) OnStatusChanged(
+	f func(ctx context.Context, event *WebhookEvent, request *Request) error,
+) {
+	h.onStatusChanged = f
+}
+
+// OnReceiptReady registers the callback for `EventReceiptReady`.
+func (h *WebhookHandler) OnReceiptReady(f func(ctx context.Context, event *WebhookEvent) error) {
+	h.onReceiptReady = f
+}
+
+// OnError registers a callback for the webhooks that are rejected, eg: with
+// `ErrWebhookSignature`, and the errors of the other callbacks.
+func (h *WebhookHandler) OnError(f func(error)) {
+	h.onError = f
+}
+
+// ServeHTTP implements `http.Handler`.
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+	if err != nil {
+		h.reject(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	if !h.validSignature(body, r.Header.Get(WebhookSignatureHeader)) {
+		h.reject(w, http.StatusUnauthorized, ErrWebhookSignature)
+		return
+	}
+
+	event := new(WebhookEvent)
+	if err := json.Unmarshal(body, event); err != nil || event.EventID == "" {
+		h.reject(w, http.StatusBadRequest, fmt.Errorf("uber: malformed webhook event %q", body))
+		return
+	}
+
+	now := h.now()
+	if h.maxAge > 0 && now.Sub(event.EventTime.Time) > h.maxAge {
+		h.reject(w, http.StatusBadRequest, fmt.Errorf("%w %s", ErrWebhookStale, event.EventID))
+		return
+	}
+	if !h.reserve(event, now) {
+		h.reject(w, http.StatusOK, fmt.Errorf("%w %s", ErrWebhookDuplicate, event.EventID))
+		return
+	}
+
+	if err := h.dispatch(r.Context(), event); err != nil {
+		h.release(event.EventID)
+		h.reject(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// validSignature reports whether `signature` is the hex encoded HMAC-SHA256 of `body`.
+func (h *WebhookHandler) validSignature(body []byte, signature string) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(body)
+
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+// reserve marks `event` as seen, and reports whether it hadn't been. Events are
+// forgotten once they are too old to be accepted anyway or, if events of any age are,
+// `webhookRetention` after they were received.
+func (h *WebhookHandler) reserve(event *WebhookEvent, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for id, expiry := range h.seen {
+		if now.After(expiry) {
+			delete(h.seen, id)
+		}
+	}
+
+	if _, ok := h.seen[event.EventID]; ok {
+		return false
+	}
+	if h.maxAge > 0 {
+		h.seen[event.EventID] = event.EventTime.Add(h.maxAge)
+	} else {
+		h.seen[event.EventID] = now.Add(webhookRetention)
+	}
+
+	return true
+}
+
+// release forgets the event `id`, so that it is handled when it is sent again.
+func (h *WebhookHandler) release(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.seen, id)
+}
+
+func (h *WebhookHandler) dispatch(ctx context.Context, event *WebhookEvent) error {
+	switch event.EventType {
+	case EventStatusChanged:
+		if h.onStatusChanged == nil {
+			return nil
+		}
+
+		request, err := h.client.GetRequestContext(ctx, event.Meta.ResourceID)
+		if err != nil {
+			return err
+		}
+
+		return h.onStatusChanged(ctx, event, request)
+	case EventReceiptReady:
+		if h.onReceiptReady == nil {
+			return nil
+		}
+
+		return h.onReceiptReady(ctx, event)
+	}
+
+	// events of types added later are acknowledged and ignored
+	return nil
+}
+
+// reject answers the webhook with `status`, and passes `err` to the `OnError` callback.
+// The error itself isn't sent back.
+func (h *WebhookHandler) reject(w http.ResponseWriter, status int, err error) {
+	if h.onError != nil {
+		h.onError(err)
+	}
+
+	http.Error(w, http.StatusText(status), status)
+}
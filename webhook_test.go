@@ -0,0 +1,202 @@
+package uber
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testClientSecret = "client_secret"
+
+// testWebhookTime is the time of the test events, and the handler's clock.
+var testWebhookTime = time.Unix(1427343990, 0)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookBody(id string, eventType WebhookEventType, eventTime time.Time) string {
+	return fmt.Sprintf(`{"event_id": %q, "event_time": %d, "event_type": %q,
+"meta": {"user_id": "d13dff8b", "resource_id": "852b8fdd", "status": "accepted"},
+"resource_href": "https://api.uber.com/v1/requests/852b8fdd"}`, id, eventTime.Unix(), eventType)
+}
+
+// deliver sends a webhook to `h`, signed with `secret`, and returns the response code.
+func deliver(h http.Handler, secret, body string) int {
+	req := httptest.NewRequest("POST", "/webhooks", strings.NewReader(body))
+	req.Header.Set(WebhookSignatureHeader, sign(secret, body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec.Code
+}
+
+func newTestWebhookHandler(
+	t *testing.T, apiHost string, opts ...WebhookOption,
+) (*WebhookHandler, *[]error) {
+	client := NewClient(testServerToken, WithAPIHost(apiHost))
+	client.SetCredentials("client_id", testClientSecret, "http://localhost/callback")
+
+	h, err := client.WebhookHandler(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.now = func() time.Time { return testWebhookTime.Add(time.Minute) }
+
+	errs := new([]error)
+	h.OnError(func(err error) { *errs = append(*errs, err) })
+
+	return h, errs
+}
+
+func TestWebhookHandler(t *testing.T) {
+	t.Parallel()
+
+	api := newRecordingServer(`{"request_id": "852b8fdd", "status": "accepted"}`)
+	defer api.Close()
+	h, errs := newTestWebhookHandler(t, api.URL)
+
+	var statuses []*Request
+	h.OnStatusChanged(func(ctx context.Context, event *WebhookEvent, request *Request) error {
+		if event.EventID != "3a3f3da4" || event.Meta.Status != StatusAccepted ||
+			!event.EventTime.Equal(testWebhookTime) {
+			t.Errorf("unexpected event %+v", event)
+		}
+		statuses = append(statuses, request)
+		return nil
+	})
+	var receipts []string
+	h.OnReceiptReady(func(ctx context.Context, event *WebhookEvent) error {
+		receipts = append(receipts, event.Meta.ResourceID)
+		return nil
+	})
+
+	body := webhookBody("3a3f3da4", EventStatusChanged, testWebhookTime)
+	if code := deliver(h, testClientSecret, body); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(statuses) != 1 || statuses[0].RequestID != "852b8fdd" ||
+		statuses[0].Status != StatusAccepted {
+		t.Fatalf("expected the request, got %+v", statuses)
+	}
+	if len(api.requests) != 1 || api.requests[0] != "GET /requests/852b8fdd" {
+		t.Fatalf("expected the request to be resolved with GetRequest, got %v", api.requests)
+	}
+
+	// the same event again is acknowledged, but not dispatched
+	if code := deliver(h, testClientSecret, body); code != http.StatusOK {
+		t.Fatalf("expected 200 for a duplicate, got %d", code)
+	}
+	if len(statuses) != 1 || len(*errs) != 1 || !errors.Is((*errs)[0], ErrWebhookDuplicate) {
+		t.Fatalf("expected the duplicate to be dropped, got %d callbacks and %v", len(statuses), *errs)
+	}
+
+	body = webhookBody("7a84a3b2", EventReceiptReady, testWebhookTime)
+	if code := deliver(h, testClientSecret, body); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(receipts) != 1 || receipts[0] != "852b8fdd" {
+		t.Fatalf("expected a receipt, got %v", receipts)
+	}
+}
+
+func TestWebhookHandlerRejects(t *testing.T) {
+	t.Parallel()
+
+	h, errs := newTestWebhookHandler(t, "http://localhost:0")
+	h.OnReceiptReady(func(ctx context.Context, event *WebhookEvent) error {
+		t.Errorf("unexpected event %+v", event)
+		return nil
+	})
+
+	body := webhookBody("3a3f3da4", EventReceiptReady, testWebhookTime)
+	if code := deliver(h, "wrong secret", body); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a bad signature, got %d", code)
+	}
+	if !errors.Is((*errs)[0], ErrWebhookSignature) {
+		t.Fatalf("expected ErrWebhookSignature, got %v", (*errs)[0])
+	}
+
+	body = webhookBody("3a3f3da4", EventReceiptReady, testWebhookTime.Add(-time.Hour))
+	if code := deliver(h, testClientSecret, body); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a stale event, got %d", code)
+	}
+	if !errors.Is((*errs)[1], ErrWebhookStale) {
+		t.Fatalf("expected ErrWebhookStale, got %v", (*errs)[1])
+	}
+
+	if code := deliver(h, testClientSecret, `{"oops"`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a malformed event, got %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/webhooks", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for a GET, got %d", rec.Code)
+	}
+
+	if _, err := NewClient(testServerToken).WebhookHandler(); err == nil {
+		t.Fatal("expected an error without a client secret")
+	}
+}
+
+func TestWebhookHandlerRetry(t *testing.T) {
+	t.Parallel()
+
+	h, _ := newTestWebhookHandler(t, "http://localhost:0", WithWebhookSecret("webhook_secret"))
+
+	calls := 0
+	h.OnReceiptReady(func(ctx context.Context, event *WebhookEvent) error {
+		if calls++; calls == 1 {
+			return errors.New("database is down")
+		}
+		return nil
+	})
+
+	// a failed event gets a 500, and is handled again when Uber retries it
+	body := webhookBody("3a3f3da4", EventReceiptReady, testWebhookTime)
+	if code := deliver(h, "webhook_secret", body); code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", code)
+	}
+	if code := deliver(h, "webhook_secret", body); code != http.StatusOK || calls != 2 {
+		t.Fatalf("expected the retry to be handled, got %d after %d calls", code, calls)
+	}
+}
+
+func TestWebhookHandlerAnyAge(t *testing.T) {
+	t.Parallel()
+
+	h, _ := newTestWebhookHandler(t, "http://localhost:0", WithWebhookMaxAge(0))
+	h.OnReceiptReady(func(ctx context.Context, event *WebhookEvent) error { return nil })
+
+	now := testWebhookTime.Add(time.Minute)
+	h.now = func() time.Time { return now }
+
+	body := webhookBody("3a3f3da4", EventReceiptReady, testWebhookTime.Add(-24*time.Hour))
+	if code := deliver(h, testClientSecret, body); code != http.StatusOK {
+		t.Fatalf("expected an old event to be accepted, got %d", code)
+	}
+	if code := deliver(h, testClientSecret, body); code != http.StatusOK || len(h.seen) != 1 {
+		t.Fatalf("expected the duplicate to be dropped, got %d and %d seen", code, len(h.seen))
+	}
+
+	// events are forgotten once the retention window is over
+	now = now.Add(webhookRetention + time.Second)
+	body = webhookBody("4b4f3da4", EventReceiptReady, testWebhookTime)
+	if code := deliver(h, testClientSecret, body); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if _, ok := h.seen["3a3f3da4"]; ok || len(h.seen) != 1 {
+		t.Fatalf("expected only the last event to be remembered, got %v", h.seen)
+	}
+}